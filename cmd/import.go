@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/reinhardjs/dot-backend-test/internal/delivery/http/dto"
+	"github.com/reinhardjs/dot-backend-test/internal/usecase"
+	"github.com/reinhardjs/dot-backend-test/pkg/audit"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+)
+
+// runImport implements the import command, which imports products the same
+// way as POST /api/v1/products:import:
+//
+//	go run ./cmd import [-dry-run] [-create-categories] [-format csv|ndjson] FILE
+//
+// FILE may be "-" to read standard input. The report is printed as JSON.
+func runImport(productUsecase usecase.ProductUsecase, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "report what would change without writing anything")
+	createCategories := flags.Bool("create-categories", false, "create categories referenced by name that do not exist")
+	format := flags.String("format", "", "csv or ndjson (default: from the file extension)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: import [flags] FILE")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	path := flags.Arg(0)
+	if *format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			*format = dto.FormatCSV
+		case ".ndjson", ".jsonl":
+			*format = dto.FormatNDJSON
+		default:
+			return fmt.Errorf("cannot tell the format of %s, use -format", path)
+		}
+	}
+
+	var input io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+
+	rows, err := dto.DecodeProductImport(input, *format)
+	if err != nil {
+		return describeImportError(err)
+	}
+	opts := usecase.ImportOptions{DryRun: *dryRun, CreateCategories: *createCategories}
+	// Audit entries of command line imports name the importing user.
+	ctx := audit.WithActor(context.Background(), importActor())
+	report, err := productUsecase.ImportProducts(ctx, dto.NewProductImportRows(rows), opts)
+	if err != nil {
+		return describeImportError(err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(dto.NewProductImportResponse(report))
+}
+
+// describeImportError lists every rejected row of a validation error.
+func describeImportError(err error) error {
+	var domainErr *errors.Error
+	if !errors.As(err, &domainErr) || len(domainErr.Fields) == 0 {
+		return err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d invalid fields", len(domainErr.Fields))
+	for _, field := range domainErr.Fields {
+		fmt.Fprintf(&b, "\n  line %d: %s", field.Row, field.Message)
+	}
+	return fmt.Errorf("%s", b.String())
+}
+
+func importActor() string {
+	if user := os.Getenv("USER"); user != "" {
+		return "cli:" + user
+	}
+	return "cli"
+}
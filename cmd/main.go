@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
 
 	"github.com/reinhardjs/dot-backend-test/config"
 	"github.com/reinhardjs/dot-backend-test/internal/delivery/http"
@@ -19,24 +21,41 @@ func main() {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	cache, err := cache.NewRedisClient(cfg.RedisURL)
+	appCache, err := cache.New(cfg)
 	if err != nil {
-		log.Fatalf("Failed to connect to redis: %v", err)
+		log.Fatalf("Failed to initialize cache: %v", err)
 	}
-	redisClient := cache.Client
 
 	// Run migrations
-	err = db.AutoMigrate(&entity.Category{}, &entity.Product{})
+	err = db.AutoMigrate(&entity.Category{}, &entity.Product{}, &entity.AuditEntry{})
 	if err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
 	log.Println("Migrations completed successfully")
 
-	productUsecase := usecase.NewProductUsecase(db, cache)
-	categoryUsecase := usecase.NewCategoryUsecase(db, redisClient)
+	loader := cache.NewLoaderFromConfig(appCache, cfg)
 
-	router := http.NewRouter(productUsecase, categoryUsecase)
+	productUsecase := usecase.NewProductUsecase(db, appCache, loader)
+	categoryUsecase := usecase.NewCategoryUsecase(db, appCache, loader)
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "import":
+			if err := runImport(productUsecase, os.Args[2:]); err != nil {
+				log.Fatalf("Import failed: %v", err)
+			}
+		default:
+			log.Fatalf("Unknown command %q", os.Args[1])
+		}
+		return
+	}
+
+	if cfg.TrashRetention > 0 && cfg.TrashPurgeInterval > 0 {
+		go runTrashPurger(context.Background(), productUsecase, categoryUsecase, cfg.TrashRetention, cfg.TrashPurgeInterval)
+	}
+
+	router := http.NewRouter(cfg, appCache, productUsecase, categoryUsecase)
 
 	log.Printf("Server starting on %s", cfg.ServerAddress)
 	if err := router.Run(cfg.ServerAddress); err != nil {
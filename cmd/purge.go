@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/reinhardjs/dot-backend-test/internal/usecase"
+)
+
+// runTrashPurger permanently deletes products and categories that have been
+// in the trash for longer than retention, once at startup and then every
+// interval, until ctx is done. Products go first, so that categories they
+// kept in use can be purged in the same run.
+func runTrashPurger(ctx context.Context, productUsecase usecase.ProductUsecase, categoryUsecase usecase.CategoryUsecase, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		before := time.Now().Add(-retention)
+		products, err := productUsecase.PurgeDeletedProducts(ctx, before)
+		if err != nil {
+			log.Printf("Failed to purge deleted products: %v", err)
+		}
+		categories, err := categoryUsecase.PurgeDeletedCategories(ctx, before)
+		if err != nil {
+			log.Printf("Failed to purge deleted categories: %v", err)
+		}
+		if products > 0 || categories > 0 {
+			log.Printf("Purged %d products and %d categories deleted before %s", products, categories, before.Format(time.RFC3339))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,13 +1,41 @@
 package config
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
 type Config struct {
 	DatabaseURL   string
 	RedisURL      string
+	RedisTimeout  time.Duration
 	ServerAddress string
+	ServerTimeout time.Duration
+	BulkTimeout   time.Duration
+	CursorSecret  string
+
+	RequireIfMatch bool
+	CacheControl   string
+
+	IdempotencyTTL     time.Duration
+	IdempotencyLockTTL time.Duration
+
+	TrashRetention     time.Duration
+	TrashPurgeInterval time.Duration
+
+	TrustActorHeader bool
+
+	CacheDriver           string
+	CacheMaxEntries       int
+	CacheMaxTTL           time.Duration
+	CacheTTL              time.Duration
+	CacheTTLJitter        float64
+	CacheStaleTTL         time.Duration
+	CacheLockTTL          time.Duration
+	CacheNegativeTTL      time.Duration
+	CacheBreakerThreshold int
+	CacheBreakerCooldown  time.Duration
 }
 
 func Load() *Config {
@@ -21,9 +49,48 @@ func Load() *Config {
 		panic(err)
 	}
 
+	viper.SetDefault("cache.driver", "redis")
+	viper.SetDefault("redis.timeout", "250ms")
+	viper.SetDefault("server.bulk_timeout", 600)
+	viper.SetDefault("cache.ttl", "5m")
+	viper.SetDefault("cache.ttl_jitter", 0.1)
+	viper.SetDefault("cache.stale_ttl", "1m")
+	viper.SetDefault("cache.negative_ttl", "30s")
+	viper.SetDefault("http_cache.cache_control", "no-cache")
+	viper.SetDefault("idempotency.ttl", "24h")
+	viper.SetDefault("idempotency.lock_ttl", "1m")
+	viper.SetDefault("trash.retention", "720h")
+	viper.SetDefault("trash.purge_interval", "1h")
+
 	return &Config{
 		DatabaseURL:   viper.GetString("database.url"),
 		RedisURL:      viper.GetString("redis.url"),
+		RedisTimeout:  viper.GetDuration("redis.timeout"),
 		ServerAddress: viper.GetString("server.address"),
+		ServerTimeout: time.Duration(viper.GetInt("server.timeout")) * time.Second,
+		BulkTimeout:   time.Duration(viper.GetInt("server.bulk_timeout")) * time.Second,
+		CursorSecret:  viper.GetString("pagination.cursor_secret"),
+
+		RequireIfMatch: viper.GetBool("concurrency.require_if_match"),
+		CacheControl:   viper.GetString("http_cache.cache_control"),
+
+		IdempotencyTTL:     viper.GetDuration("idempotency.ttl"),
+		IdempotencyLockTTL: viper.GetDuration("idempotency.lock_ttl"),
+
+		TrashRetention:     viper.GetDuration("trash.retention"),
+		TrashPurgeInterval: viper.GetDuration("trash.purge_interval"),
+
+		TrustActorHeader: viper.GetBool("audit.trust_actor_header"),
+
+		CacheDriver:           viper.GetString("cache.driver"),
+		CacheMaxEntries:       viper.GetInt("cache.memory.max_entries"),
+		CacheMaxTTL:           viper.GetDuration("cache.memory.max_ttl"),
+		CacheTTL:              viper.GetDuration("cache.ttl"),
+		CacheTTLJitter:        viper.GetFloat64("cache.ttl_jitter"),
+		CacheStaleTTL:         viper.GetDuration("cache.stale_ttl"),
+		CacheLockTTL:          viper.GetDuration("cache.lock_ttl"),
+		CacheNegativeTTL:      viper.GetDuration("cache.negative_ttl"),
+		CacheBreakerThreshold: viper.GetInt("cache.breaker.failure_threshold"),
+		CacheBreakerCooldown:  viper.GetDuration("cache.breaker.cooldown"),
 	}
 }
@@ -0,0 +1,175 @@
+package dto
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+)
+
+func init() {
+	// Report fields by their JSON names rather than their Go names.
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(jsonFieldName)
+	}
+}
+
+// Bind decodes the JSON request body into req, trims surrounding whitespace
+// from its string fields and validates it against its binding tags. Every
+// violated field is reported in a single validation error.
+func Bind(c *gin.Context, req interface{}) error {
+	return Decode(c.Request.Body, req)
+}
+
+// Decode is Bind for a body that is not read from the request, such as the
+// result of applying a patch.
+func Decode(body io.Reader, req interface{}) error {
+	if err := decode(body, req); err != nil {
+		return err
+	}
+	trimStrings(reflect.ValueOf(req))
+	return Validate(req)
+}
+
+func decode(body io.Reader, req interface{}) error {
+	if body == nil {
+		return errors.Validation("request body is required").WithCode("invalid_body")
+	}
+	err := json.NewDecoder(body).Decode(req)
+	if err == nil {
+		return nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return errors.InvalidField(typeErr.Field, "%s must be of type %s", typeErr.Field, jsonType(typeErr.Type))
+	case errors.Is(err, io.EOF):
+		return errors.Validation("request body is required").WithCode("invalid_body")
+	}
+	return errors.Validation("request body is not valid JSON").WithCode("invalid_body")
+}
+
+// Validate checks req against its binding tags.
+func Validate(req interface{}) error {
+	err := binding.Validator.ValidateStruct(req)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return errors.Validation("%v", err)
+	}
+	fields := make([]errors.FieldError, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		fields = append(fields, errors.FieldError{Field: fieldPath(fe), Message: fieldMessage(fe)})
+	}
+	return errors.InvalidFields(fields...)
+}
+
+// fieldPath is the JSON path of the field relative to the validated struct,
+// such as "name" or "product.name".
+func fieldPath(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if i := strings.IndexByte(namespace, '.'); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required", "required_unless":
+		return fmt.Sprintf("%s is required", fe.Field())
+	case "required_without":
+		return fmt.Sprintf("either %s or %s is required", fe.Field(), strings.ToLower(fe.Param()))
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s", fe.Field(), strings.ReplaceAll(fe.Param(), " ", ", "))
+	case "min":
+		if fe.Kind() == reflect.Slice {
+			return fmt.Sprintf("%s must contain at least %s items", fe.Field(), fe.Param())
+		}
+		return fmt.Sprintf("%s must be at least %s", fe.Field(), fe.Param())
+	case "max":
+		switch fe.Kind() {
+		case reflect.String:
+			return fmt.Sprintf("%s must be at most %s characters long", fe.Field(), fe.Param())
+		case reflect.Slice:
+			return fmt.Sprintf("%s must contain at most %s items", fe.Field(), fe.Param())
+		}
+		return fmt.Sprintf("%s must be at most %s", fe.Field(), fe.Param())
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", fe.Field(), fe.Param())
+	case "gte":
+		return fmt.Sprintf("%s must be greater than or equal to %s", fe.Field(), fe.Param())
+	case "lte":
+		return fmt.Sprintf("%s must be less than or equal to %s", fe.Field(), fe.Param())
+	}
+	return fmt.Sprintf("%s is invalid", fe.Field())
+}
+
+func trimStrings(v reflect.Value) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(strings.TrimSpace(field.String()))
+		case reflect.Ptr, reflect.Struct:
+			trimStrings(field)
+		case reflect.Slice:
+			for j := 0; j < field.Len(); j++ {
+				trimStrings(field.Index(j))
+			}
+		}
+	}
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+func jsonType(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+	return "object"
+}
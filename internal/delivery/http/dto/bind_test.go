@@ -0,0 +1,94 @@
+package dto
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func bindBody(body string, req interface{}) error {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest("POST", "/", strings.NewReader(body))
+	return Bind(c, req)
+}
+
+func fieldErrors(t *testing.T, err error) map[string]string {
+	var domainErr *errors.Error
+	if !assert.True(t, errors.As(err, &domainErr)) {
+		return nil
+	}
+	assert.Equal(t, errors.KindValidation, domainErr.Kind)
+	fields := make(map[string]string)
+	for _, field := range domainErr.Fields {
+		fields[field.Field] = field.Message
+	}
+	return fields
+}
+
+func TestBindProductRequest(t *testing.T) {
+	// Valid requests are trimmed
+	var req ProductRequest
+	err := bindBody(`{"name": "  Phone  ", "price": 0, "category_id": 3}`, &req)
+	assert.NoError(t, err)
+	assert.Equal(t, "Phone", req.Name)
+	assert.Equal(t, 0.0, *req.Price)
+
+	// Every violated field is reported at once; whitespace-only names are empty
+	req = ProductRequest{}
+	err = bindBody(`{"name": "   ", "price": -1}`, &req)
+	assert.Equal(t, map[string]string{
+		"name":        "name is required",
+		"price":       "price must be greater than or equal to 0",
+		"category_id": "category_id is required",
+	}, fieldErrors(t, err))
+
+	req = ProductRequest{}
+	err = bindBody(`{"name": "`+strings.Repeat("x", 101)+`", "category_id": 1}`, &req)
+	assert.Equal(t, map[string]string{
+		"name":  "name must be at most 100 characters long",
+		"price": "price is required",
+	}, fieldErrors(t, err))
+
+	// Type mismatches name the field
+	req = ProductRequest{}
+	err = bindBody(`{"name": "Phone", "price": "cheap", "category_id": 1}`, &req)
+	assert.Equal(t, map[string]string{"price": "price must be of type number"}, fieldErrors(t, err))
+
+	// Malformed and empty bodies
+	err = bindBody(`{"name": `, &ProductRequest{})
+	assert.Equal(t, errors.KindValidation, errors.KindOf(err))
+	err = bindBody(``, &ProductRequest{})
+	assert.EqualError(t, err, "request body is required")
+}
+
+func TestBindProductBatchRequest(t *testing.T) {
+	var req ProductBatchRequest
+	err := bindBody(`{"mode": "best_effort", "operations": [
+		{"op": "create", "product": {"name": " Phone ", "price": 1, "category_id": 1}},
+		{"op": "delete"},
+		{"op": "update", "id": 1, "product": {"name": ""}}
+	]}`, &req)
+	assert.NoError(t, err)
+	assert.Equal(t, "Phone", req.Operations[0].Product.Name)
+
+	// Operations are validated one by one, reporting nested fields by path
+	assert.NoError(t, Validate(&req.Operations[0]))
+	assert.Equal(t, map[string]string{"id": "id is required"}, fieldErrors(t, Validate(&req.Operations[1])))
+	assert.Equal(t, map[string]string{
+		"product.name":        "name is required",
+		"product.price":       "price is required",
+		"product.category_id": "category_id is required",
+	}, fieldErrors(t, Validate(&req.Operations[2])))
+
+	req = ProductBatchRequest{}
+	err = bindBody(`{"mode": "eventually", "operations": []}`, &req)
+	assert.Equal(t, map[string]string{
+		"mode":       "mode must be one of atomic, best_effort",
+		"operations": "operations must contain at least 1 items",
+	}, fieldErrors(t, err))
+}
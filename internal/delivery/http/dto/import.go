@@ -0,0 +1,257 @@
+package dto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/reinhardjs/dot-backend-test/internal/domain/entity"
+	"github.com/reinhardjs/dot-backend-test/internal/usecase"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+	"github.com/reinhardjs/dot-backend-test/pkg/export"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// ProductImportRow is a product on a line of an import file. The category is
+// given either by ID or by name.
+type ProductImportRow struct {
+	Line        int      `json:"-"`
+	ExternalKey string   `json:"external_key" binding:"required,max=100"`
+	Name        string   `json:"name" binding:"required,max=100"`
+	Price       *float64 `json:"price" binding:"required,gte=0,lte=1000000000"`
+	CategoryID  uint     `json:"category_id" binding:"required_without=Category"`
+	Category    string   `json:"category" binding:"max=100"`
+}
+
+func (r ProductImportRow) ToEntity() entity.Product {
+	key := r.ExternalKey
+	product := entity.Product{Name: r.Name, CategoryID: r.CategoryID, ExternalKey: &key}
+	if r.Price != nil {
+		product.Price = *r.Price
+	}
+	return product
+}
+
+// NewProductImportRows converts decoded rows for ProductUsecase.ImportProducts.
+func NewProductImportRows(rows []ProductImportRow) []usecase.ProductImportRow {
+	imports := make([]usecase.ProductImportRow, len(rows))
+	for i, row := range rows {
+		imports[i] = usecase.ProductImportRow{Line: row.Line, Product: row.ToEntity(), Category: row.Category}
+	}
+	return imports
+}
+
+type ProductImportResponse struct {
+	DryRun            bool     `json:"dry_run"`
+	Rows              int      `json:"rows"`
+	Created           int      `json:"created"`
+	Updated           int      `json:"updated"`
+	Unchanged         int      `json:"unchanged"`
+	CategoriesCreated []string `json:"categories_created"`
+}
+
+func NewProductImportResponse(report usecase.ImportReport) ProductImportResponse {
+	categories := report.CategoriesCreated
+	if categories == nil {
+		categories = []string{}
+	}
+	return ProductImportResponse{
+		DryRun:            report.DryRun,
+		Rows:              report.Rows,
+		Created:           report.Created,
+		Updated:           report.Updated,
+		Unchanged:         report.Unchanged,
+		CategoriesCreated: categories,
+	}
+}
+
+// DecodeProductImport reads every row of a CSV or NDJSON import file and
+// validates it. All invalid rows are reported together, by line, in a single
+// validation error. CSV files start with a header naming the columns, in any
+// order; unknown columns are ignored.
+func DecodeProductImport(r io.Reader, format string) ([]ProductImportRow, error) {
+	var rows []ProductImportRow
+	var fields []errors.FieldError
+	var err error
+	switch format {
+	case FormatCSV:
+		rows, fields, err = decodeCSV(r)
+	case FormatNDJSON:
+		rows, fields, err = decodeNDJSON(r)
+	default:
+		return nil, errors.Validation("unsupported import format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 && len(fields) == 0 {
+		return nil, errors.Validation("import file contains no rows").WithCode("invalid_body")
+	}
+
+	lines := make(map[string]int, len(rows))
+	valid := rows[:0]
+	for _, row := range rows {
+		trimStrings(reflect.ValueOf(&row))
+		if err := Validate(&row); err != nil {
+			var domainErr *errors.Error
+			errors.As(err, &domainErr)
+			for _, field := range domainErr.Fields {
+				field.Row = row.Line
+				fields = append(fields, field)
+			}
+			continue
+		}
+		if line, ok := lines[row.ExternalKey]; ok {
+			fields = append(fields, errors.FieldError{
+				Row:     row.Line,
+				Field:   "external_key",
+				Message: fmt.Sprintf("external_key %q is already used on line %d", row.ExternalKey, line),
+			})
+			continue
+		}
+		lines[row.ExternalKey] = row.Line
+		valid = append(valid, row)
+	}
+	if len(fields) > 0 {
+		return nil, errors.InvalidFields(fields...)
+	}
+	return valid, nil
+}
+
+func decodeCSV(r io.Reader) ([]ProductImportRow, []errors.FieldError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, readError(err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		// Spreadsheet exports often start with a byte order mark.
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+	for _, name := range []string{"external_key", "name", "price"} {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, errors.Validation("CSV header has no %s column", name).WithCode("invalid_body")
+		}
+	}
+	_, hasID := columns["category_id"]
+	_, hasName := columns["category"]
+	if !hasID && !hasName {
+		return nil, nil, errors.Validation("CSV header has neither a category_id nor a category column").WithCode("invalid_body")
+	}
+
+	var rows []ProductImportRow
+	var fields []errors.FieldError
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, readError(err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		value := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		// Text cells of our own exports are escaped against formulas.
+		text := func(name string) string {
+			return export.UnescapeFormula(value(name))
+		}
+		row := ProductImportRow{
+			Line:        line,
+			ExternalKey: text("external_key"),
+			Name:        text("name"),
+			Category:    text("category"),
+		}
+
+		valid := true
+		if raw := value("price"); raw != "" {
+			price, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				fields = append(fields, errors.FieldError{Row: line, Field: "price", Message: "price must be of type number"})
+				valid = false
+			}
+			row.Price = &price
+		}
+		if raw := value("category_id"); raw != "" {
+			id, err := strconv.ParseUint(raw, 10, 32)
+			if err != nil {
+				fields = append(fields, errors.FieldError{Row: line, Field: "category_id", Message: "category_id must be of type integer"})
+				valid = false
+			}
+			row.CategoryID = uint(id)
+		}
+		if valid {
+			rows = append(rows, row)
+		}
+	}
+	return rows, fields, nil
+}
+
+func decodeNDJSON(r io.Reader) ([]ProductImportRow, []errors.FieldError, error) {
+	reader := bufio.NewReader(r)
+	var rows []ProductImportRow
+	var fields []errors.FieldError
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, nil, readError(err)
+		}
+
+		if data = bytes.TrimSpace(data); len(data) > 0 {
+			row := ProductImportRow{Line: line}
+			var typeErr *json.UnmarshalTypeError
+			switch decodeErr := json.Unmarshal(data, &row); {
+			case decodeErr == nil:
+				rows = append(rows, row)
+			case errors.As(decodeErr, &typeErr) && typeErr.Field != "":
+				fields = append(fields, errors.FieldError{
+					Row:     line,
+					Field:   typeErr.Field,
+					Message: fmt.Sprintf("%s must be of type %s", typeErr.Field, jsonType(typeErr.Type)),
+				})
+			default:
+				fields = append(fields, errors.FieldError{Row: line, Message: "line is not a valid JSON object"})
+			}
+		}
+
+		if err == io.EOF {
+			return rows, fields, nil
+		}
+	}
+}
+
+func readError(err error) error {
+	var parseErr *csv.ParseError
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &parseErr):
+		return errors.Validation("line %d of the CSV file is malformed: %v", parseErr.Line, parseErr.Err).WithCode("invalid_body")
+	case errors.As(err, &maxBytesErr):
+		return errors.PayloadTooLarge("import file must not be larger than %d bytes", maxBytesErr.Limit)
+	}
+	return errors.Unavailable(err, "failed to read import file")
+}
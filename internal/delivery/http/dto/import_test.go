@@ -0,0 +1,85 @@
+package dto
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func rowErrors(t *testing.T, err error) map[int]map[string]string {
+	var domainErr *errors.Error
+	if !assert.True(t, errors.As(err, &domainErr)) {
+		return nil
+	}
+	rows := make(map[int]map[string]string)
+	for _, field := range domainErr.Fields {
+		if rows[field.Row] == nil {
+			rows[field.Row] = make(map[string]string)
+		}
+		rows[field.Row][field.Field] = field.Message
+	}
+	return rows
+}
+
+func TestDecodeProductImportCSV(t *testing.T) {
+	rows, err := DecodeProductImport(strings.NewReader("\ufeffName,External_Key,Price,Category,Notes\n"+
+		" Phone ,SKU-1,9.5,Electronics,ignored\n"+
+		"\n"+
+		"\"Desk, oak\",SKU-2,120,Furniture\n"), FormatCSV)
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 2) {
+		assert.Equal(t, ProductImportRow{Line: 2, ExternalKey: "SKU-1", Name: "Phone", Price: rows[0].Price, Category: "Electronics"}, rows[0])
+		assert.Equal(t, 9.5, *rows[0].Price)
+		assert.Equal(t, 4, rows[1].Line)
+		assert.Equal(t, "Desk, oak", rows[1].Name)
+	}
+
+	// Text cells escaped by a CSV export are read back unescaped
+	rows, err = DecodeProductImport(strings.NewReader("external_key,name,price,category\n"+
+		"'-SKU-1,'=Phone,5,'@Home\n"), FormatCSV)
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 1) {
+		assert.Equal(t, "-SKU-1", rows[0].ExternalKey)
+		assert.Equal(t, "=Phone", rows[0].Name)
+		assert.Equal(t, "@Home", rows[0].Category)
+	}
+
+	// Every invalid row is reported by line
+	_, err = DecodeProductImport(strings.NewReader("external_key,name,price,category_id\n"+
+		"SKU-1,Phone,cheap,1\n"+
+		"SKU-2,,5,\n"+
+		"SKU-3,Desk,5,2\n"+
+		"SKU-3,Chair,5,2\n"), FormatCSV)
+	assert.Equal(t, map[int]map[string]string{
+		2: {"price": "price must be of type number"},
+		3: {"name": "name is required", "category_id": "either category_id or category is required"},
+		5: {"external_key": `external_key "SKU-3" is already used on line 4`},
+	}, rowErrors(t, err))
+
+	_, err = DecodeProductImport(strings.NewReader("external_key,name,category\n"), FormatCSV)
+	assert.EqualError(t, err, "CSV header has no price column")
+	_, err = DecodeProductImport(strings.NewReader("external_key,name,price,category\n"), FormatCSV)
+	assert.EqualError(t, err, "import file contains no rows")
+}
+
+func TestDecodeProductImportNDJSON(t *testing.T) {
+	rows, err := DecodeProductImport(strings.NewReader(`{"external_key": "SKU-1", "name": "Phone", "price": 9.5, "category_id": 1}`+"\n\n"+
+		`{"external_key": "SKU-2", "name": "Desk", "price": 120, "category": "Furniture"}`), FormatNDJSON)
+	assert.NoError(t, err)
+	if assert.Len(t, rows, 2) {
+		assert.Equal(t, uint(1), rows[0].CategoryID)
+		assert.Equal(t, 3, rows[1].Line)
+		assert.Equal(t, "Furniture", rows[1].Category)
+	}
+
+	_, err = DecodeProductImport(strings.NewReader(`{"external_key": "SKU-1", "name": "Phone", "price": "cheap", "category_id": 1}`+"\n"+
+		`not json`+"\n"+
+		`{"external_key": "SKU-3", "name": "Desk", "price": -1, "category_id": 1}`), FormatNDJSON)
+	assert.Equal(t, map[int]map[string]string{
+		1: {"price": "price must be of type number"},
+		2: {"": "line is not a valid JSON object"},
+		3: {"price": "price must be greater than or equal to 0"},
+	}, rowErrors(t, err))
+}
@@ -0,0 +1,98 @@
+package dto
+
+import "github.com/reinhardjs/dot-backend-test/internal/domain/entity"
+
+type ProductRequest struct {
+	Name       string   `json:"name" binding:"required,max=100"`
+	Price      *float64 `json:"price" binding:"required,gte=0,lte=1000000000"`
+	CategoryID uint     `json:"category_id" binding:"required,gt=0"`
+}
+
+func NewProductRequest(product entity.Product) ProductRequest {
+	price := product.Price
+	return ProductRequest{Name: product.Name, Price: &price, CategoryID: product.CategoryID}
+}
+
+func (r ProductRequest) ToEntity() entity.Product {
+	product := entity.Product{Name: r.Name, CategoryID: r.CategoryID}
+	if r.Price != nil {
+		product.Price = *r.Price
+	}
+	return product
+}
+
+// ApplyTo copies the request onto product and returns the names of the
+// fields that changed.
+func (r ProductRequest) ApplyTo(product *entity.Product) []string {
+	var fields []string
+	if r.Name != product.Name {
+		product.Name = r.Name
+		fields = append(fields, "Name")
+	}
+	if r.Price != nil && *r.Price != product.Price {
+		product.Price = *r.Price
+		fields = append(fields, "Price")
+	}
+	if r.CategoryID != product.CategoryID {
+		product.CategoryID = r.CategoryID
+		fields = append(fields, "CategoryID")
+	}
+	return fields
+}
+
+// CategoryRequest places the category below ParentID, or at the root when
+// ParentID is null or omitted.
+type CategoryRequest struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	ParentID *uint  `json:"parent_id" binding:"omitempty,gt=0"`
+}
+
+func (r CategoryRequest) ToEntity() entity.Category {
+	return entity.Category{Name: r.Name, ParentID: r.ParentID}
+}
+
+func NewCategoryRequest(category entity.Category) CategoryRequest {
+	return CategoryRequest{Name: category.Name, ParentID: category.ParentID}
+}
+
+// ApplyTo copies the request onto category and returns the names of the
+// fields that changed.
+func (r CategoryRequest) ApplyTo(category *entity.Category) []string {
+	var fields []string
+	if r.Name != category.Name {
+		category.Name = r.Name
+		fields = append(fields, "Name")
+	}
+	if !equalIDs(r.ParentID, category.ParentID) {
+		category.ParentID = r.ParentID
+		fields = append(fields, "ParentID")
+	}
+	return fields
+}
+
+func equalIDs(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+const (
+	BatchModeAtomic     = "atomic"
+	BatchModeBestEffort = "best_effort"
+)
+
+// ProductBatchRequest is the body of POST /products:batch. Operations are
+// validated one by one, so that in best-effort mode an invalid operation only
+// fails itself.
+type ProductBatchRequest struct {
+	Mode       string                  `json:"mode" binding:"omitempty,oneof=atomic best_effort"`
+	Operations []ProductBatchOperation `json:"operations" binding:"required,min=1,max=1000"`
+}
+
+type ProductBatchOperation struct {
+	Op      string          `json:"op" binding:"required,oneof=create update delete"`
+	ID      uint            `json:"id" binding:"required_unless=Op create"`
+	Version uint            `json:"version"`
+	Product *ProductRequest `json:"product" binding:"required_unless=Op delete"`
+}
@@ -0,0 +1,191 @@
+package dto
+
+import (
+	"cmp"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/reinhardjs/dot-backend-test/internal/domain/entity"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+)
+
+type CategoryResponse struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	ParentID  *uint     `json:"parent_id"`
+	Version   uint      `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func NewCategoryResponse(category entity.Category) CategoryResponse {
+	return CategoryResponse{
+		ID:        category.ID,
+		Name:      category.Name,
+		ParentID:  category.ParentID,
+		Version:   category.Version,
+		CreatedAt: category.CreatedAt,
+		UpdatedAt: category.UpdatedAt,
+	}
+}
+
+func NewCategoryResponses(categories []entity.Category) []CategoryResponse {
+	responses := make([]CategoryResponse, len(categories))
+	for i, category := range categories {
+		responses[i] = NewCategoryResponse(category)
+	}
+	return responses
+}
+
+// DeletedCategoryResponse is a category in the trash.
+type DeletedCategoryResponse struct {
+	CategoryResponse
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+func NewDeletedCategoryResponses(categories []entity.Category) []DeletedCategoryResponse {
+	responses := make([]DeletedCategoryResponse, len(categories))
+	for i, category := range categories {
+		responses[i] = DeletedCategoryResponse{NewCategoryResponse(category), category.DeletedAt.Time}
+	}
+	return responses
+}
+
+type CategoryTreeNode struct {
+	CategoryResponse
+	Children []CategoryTreeNode `json:"children"`
+}
+
+// NewCategoryTree arranges categories into trees, with siblings sorted by
+// name. Categories whose parent is not among categories become roots.
+func NewCategoryTree(categories []entity.Category) []CategoryTreeNode {
+	sorted := slices.Clone(categories)
+	slices.SortFunc(sorted, func(a, b entity.Category) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.ID, b.ID))
+	})
+
+	exists := make(map[uint]bool, len(sorted))
+	for _, category := range sorted {
+		exists[category.ID] = true
+	}
+	children := make(map[uint][]entity.Category)
+	var roots []entity.Category
+	for _, category := range sorted {
+		if category.ParentID != nil && exists[*category.ParentID] {
+			children[*category.ParentID] = append(children[*category.ParentID], category)
+		} else {
+			roots = append(roots, category)
+		}
+	}
+
+	var build func(categories []entity.Category) []CategoryTreeNode
+	build = func(categories []entity.Category) []CategoryTreeNode {
+		nodes := make([]CategoryTreeNode, len(categories))
+		for i, category := range categories {
+			nodes[i] = CategoryTreeNode{
+				CategoryResponse: NewCategoryResponse(category),
+				Children:         build(children[category.ID]),
+			}
+		}
+		return nodes
+	}
+	return build(roots)
+}
+
+type ProductResponse struct {
+	ID          uint              `json:"id"`
+	Name        string            `json:"name"`
+	Price       float64           `json:"price"`
+	CategoryID  uint              `json:"category_id"`
+	Category    *CategoryResponse `json:"category,omitempty"`
+	ExternalKey *string           `json:"external_key,omitempty"`
+	Version     uint              `json:"version"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// NewProductResponse maps product to its API representation. The category is
+// embedded only when includeCategory is set and it was loaded.
+func NewProductResponse(product entity.Product, includeCategory bool) ProductResponse {
+	response := ProductResponse{
+		ID:          product.ID,
+		Name:        product.Name,
+		Price:       product.Price,
+		CategoryID:  product.CategoryID,
+		ExternalKey: product.ExternalKey,
+		Version:     product.Version,
+		CreatedAt:   product.CreatedAt,
+		UpdatedAt:   product.UpdatedAt,
+	}
+	if includeCategory && product.Category.ID != 0 {
+		category := NewCategoryResponse(product.Category)
+		response.Category = &category
+	}
+	return response
+}
+
+func NewProductResponses(products []entity.Product, includeCategory bool) []ProductResponse {
+	responses := make([]ProductResponse, len(products))
+	for i, product := range products {
+		responses[i] = NewProductResponse(product, includeCategory)
+	}
+	return responses
+}
+
+// DeletedProductResponse is a product in the trash.
+type DeletedProductResponse struct {
+	ProductResponse
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+func NewDeletedProductResponses(products []entity.Product) []DeletedProductResponse {
+	responses := make([]DeletedProductResponse, len(products))
+	for i, product := range products {
+		responses[i] = DeletedProductResponse{NewProductResponse(product, false), product.DeletedAt.Time}
+	}
+	return responses
+}
+
+// ProductBatchResult is the outcome of the operation at Index. Status is the
+// status code the operation would have had as a single request.
+type ProductBatchResult struct {
+	Index   int              `json:"index"`
+	Op      string           `json:"op"`
+	Status  int              `json:"status"`
+	Product *ProductResponse `json:"product,omitempty"`
+	Error   *errors.Problem  `json:"error,omitempty"`
+}
+
+type ProductBatchResponse struct {
+	Mode      string               `json:"mode"`
+	Succeeded int                  `json:"succeeded"`
+	Failed    int                  `json:"failed"`
+	Results   []ProductBatchResult `json:"results"`
+}
+
+// AuditEntryResponse is a write to a product or category. Changes maps each
+// changed field to its value before and after the write.
+type AuditEntryResponse struct {
+	ID        uint            `json:"id"`
+	Action    string          `json:"action"`
+	Actor     string          `json:"actor"`
+	RequestID string          `json:"request_id,omitempty"`
+	Changes   json.RawMessage `json:"changes"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func NewAuditEntryResponses(entries []entity.AuditEntry) []AuditEntryResponse {
+	responses := make([]AuditEntryResponse, len(entries))
+	for i, entry := range entries {
+		responses[i] = AuditEntryResponse{
+			ID:        entry.ID,
+			Action:    entry.Action,
+			Actor:     entry.Actor,
+			RequestID: entry.RequestID,
+			Changes:   json.RawMessage(entry.Changes),
+			CreatedAt: entry.CreatedAt,
+		}
+	}
+	return responses
+}
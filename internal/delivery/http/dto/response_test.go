@@ -0,0 +1,43 @@
+package dto
+
+import (
+	"testing"
+
+	"github.com/reinhardjs/dot-backend-test/internal/domain/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCategoryTree(t *testing.T) {
+	parent := func(id uint) *uint { return &id }
+	tree := NewCategoryTree([]entity.Category{
+		{ID: 4, Name: "Android", ParentID: parent(2)},
+		{ID: 1, Name: "Electronics"},
+		{ID: 3, Name: "Laptops", ParentID: parent(1)},
+		{ID: 2, Name: "Phones", ParentID: parent(1)},
+		{ID: 5, Name: "Books", ParentID: parent(99)},
+	})
+
+	// Categories with a missing parent become roots
+	if assert.Len(t, tree, 2) {
+		assert.Equal(t, "Books", tree[0].Name)
+		assert.Empty(t, tree[0].Children)
+		assert.NotNil(t, tree[0].Children)
+
+		electronics := tree[1]
+		if assert.Len(t, electronics.Children, 2) {
+			assert.Equal(t, "Laptops", electronics.Children[0].Name)
+			assert.Equal(t, "Phones", electronics.Children[1].Name)
+			assert.Equal(t, uint(4), electronics.Children[1].Children[0].ID)
+		}
+	}
+}
+
+func TestCategoryRequestApplyTo(t *testing.T) {
+	parentID := uint(1)
+	category := entity.Category{ID: 2, Name: "Phones", ParentID: &parentID}
+
+	same := uint(1)
+	assert.Empty(t, CategoryRequest{Name: "Phones", ParentID: &same}.ApplyTo(&category))
+	assert.Equal(t, []string{"ParentID"}, CategoryRequest{Name: "Phones"}.ApplyTo(&category))
+	assert.Nil(t, category.ParentID)
+}
@@ -1,115 +1,297 @@
 package handler
 
 import (
+	"context"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/reinhardjs/dot-backend-test/internal/delivery/http/dto"
 	"github.com/reinhardjs/dot-backend-test/internal/domain/entity"
+	"github.com/reinhardjs/dot-backend-test/internal/domain/repository"
 	"github.com/reinhardjs/dot-backend-test/internal/usecase"
+	"github.com/reinhardjs/dot-backend-test/pkg/cursor"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
 )
 
 type CategoryHandler struct {
 	usecase usecase.CategoryUsecase
+	cursors *cursor.Codec
+	opts    Options
 }
 
-func NewCategoryHandler(usecase usecase.CategoryUsecase) *CategoryHandler {
-	return &CategoryHandler{usecase: usecase}
+func NewCategoryHandler(usecase usecase.CategoryUsecase, cursors *cursor.Codec, opts Options) *CategoryHandler {
+	return &CategoryHandler{usecase: usecase, cursors: cursors, opts: opts}
 }
 
 func (h *CategoryHandler) CreateCategory(c *gin.Context) {
-	var category entity.Category
-	if err := c.ShouldBindJSON(&category); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req dto.CategoryRequest
+	if err := dto.Bind(c, &req); err != nil {
+		c.Error(err)
 		return
 	}
+	category := req.ToEntity()
 
-	if category.Name == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "category name cannot be empty"})
+	if err := h.usecase.CreateCategory(c.Request.Context(), &category); err != nil {
+		c.Error(err)
 		return
 	}
 
-	if err := h.usecase.CreateCategory(&category); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusCreated, category)
+	setETag(c, category.Version)
+	c.JSON(http.StatusCreated, dto.NewCategoryResponse(category))
 }
 
 func (h *CategoryHandler) GetCategory(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	id, err := parseID(c, "category")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		c.Error(err)
 		return
 	}
 
-	category, err := h.usecase.GetCategoryByID(uint(id))
+	category, err := h.usecase.GetCategoryByID(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		c.Error(err)
+		return
+	}
+
+	if h.opts.notModified(c, etag(category.Version), category.UpdatedAt) {
 		return
 	}
 
-	c.JSON(http.StatusOK, category)
+	c.JSON(http.StatusOK, dto.NewCategoryResponse(*category))
 }
 
 func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	h.updateCategory(c, func(current entity.Category, req *dto.CategoryRequest) error {
+		return dto.Bind(c, req)
+	})
+}
+
+func (h *CategoryHandler) PatchCategory(c *gin.Context) {
+	h.updateCategory(c, func(current entity.Category, req *dto.CategoryRequest) error {
+		return bindPatch(c, dto.NewCategoryRequest(current), req)
+	})
+}
+
+// updateCategory loads the category, binds its new representation with bind and
+// writes only the fields that changed.
+func (h *CategoryHandler) updateCategory(c *gin.Context, bind func(current entity.Category, req *dto.CategoryRequest) error) {
+	id, err := parseID(c, "category")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	match, err := parseIfMatch(c, h.opts.RequireIfMatch)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		c.Error(err)
 		return
 	}
 
-	// Check if category exists
-	_, err = h.usecase.GetCategoryByID(uint(id))
+	category, err := h.usecase.GetCategoryForUpdate(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		c.Error(err)
+		return
+	}
+	if err := match.check(category.Version); err != nil {
+		c.Error(err)
 		return
 	}
 
-	var category entity.Category
-	if err := c.ShouldBindJSON(&category); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req dto.CategoryRequest
+	if err := bind(*category, &req); err != nil {
+		c.Error(err)
 		return
 	}
 
-	category.ID = uint(id)
-	if err := h.usecase.UpdateCategory(&category); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	fields := req.ApplyTo(category)
+	if err := h.usecase.UpdateCategory(c.Request.Context(), category, fields...); err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, category)
+	setETag(c, category.Version)
+	c.JSON(http.StatusOK, dto.NewCategoryResponse(*category))
 }
 
 func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	id, err := parseID(c, "category")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		c.Error(err)
 		return
 	}
-
-	// Check if category exists
-	_, err = h.usecase.GetCategoryByID(uint(id))
+	match, err := parseIfMatch(c, h.opts.RequireIfMatch)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		c.Error(err)
+		return
+	}
+	opts, err := parseDeleteCategoryOptions(c)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	if err := h.usecase.DeleteCategory(uint(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var version uint
+	if match.present {
+		category, err := h.usecase.GetCategoryForUpdate(c.Request.Context(), id)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if err := match.check(category.Version); err != nil {
+			c.Error(err)
+			return
+		}
+		version = category.Version
+	}
+
+	if err := h.usecase.DeleteCategory(c.Request.Context(), id, version, opts); err != nil {
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
 }
 
+// parseDeleteCategoryOptions reads the on_products policy, which defaults to
+// restrict, and the target_category_id that reassign requires.
+func parseDeleteCategoryOptions(c *gin.Context) (usecase.DeleteCategoryOptions, error) {
+	opts := usecase.DeleteCategoryOptions{OnProducts: c.DefaultQuery("on_products", usecase.OnProductsRestrict)}
+	switch opts.OnProducts {
+	case usecase.OnProductsRestrict, usecase.OnProductsReassign, usecase.OnProductsCascade:
+	default:
+		return opts, errors.InvalidField("on_products", "on_products must be one of restrict, reassign, cascade")
+	}
+
+	target, err := queryUint(c, "target_category_id")
+	if err != nil {
+		return opts, err
+	}
+	switch {
+	case opts.OnProducts == usecase.OnProductsReassign && target == nil:
+		return opts, errors.InvalidField("target_category_id", "target_category_id is required when on_products is reassign")
+	case opts.OnProducts != usecase.OnProductsReassign && target != nil:
+		return opts, errors.InvalidField("target_category_id", "target_category_id is only allowed when on_products is reassign")
+	case target != nil:
+		opts.TargetCategoryID = *target
+	}
+	return opts, nil
+}
+
 func (h *CategoryHandler) GetAllCategories(c *gin.Context) {
-	categories, err := h.usecase.GetAllCategories()
+	if h.listNotModified(c) {
+		return
+	}
+
+	if isCursorRequest(c) {
+		h.getCategoriesByCursor(c)
+		return
+	}
+
+	categories, err := h.usecase.GetAllCategories(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewCategoryResponses(categories))
+}
+
+func (h *CategoryHandler) getCategoriesByCursor(c *gin.Context) {
+	sortFields, err := repository.ParseSort(c.Query("sort"), repository.CategorySortColumns)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	sort, err := cursorSort(sortFields)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
+		return
+	}
+	_, pageSize, err := parsePage(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	after, err := decodeCursor(c, h.cursors, sort)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Fetch one extra row to find out whether another page exists.
+	categories, err := h.usecase.GetCategoriesAfter(c.Request.Context(), sort, after, pageSize+1)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var nextCursor string
+	if len(categories) > pageSize {
+		categories = categories[:pageSize]
+		keyset := repository.CategoryKeyset(categories[pageSize-1], sort.Field)
+		if nextCursor, err = encodeCursor(c, h.cursors, sort, keyset); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, newCursorPageResponse(c, dto.NewCategoryResponses(categories), nextCursor))
+}
+
+// GetCategoryTree returns all categories nested below their parents.
+func (h *CategoryHandler) GetCategoryTree(c *gin.Context) {
+	if h.listNotModified(c) {
 		return
 	}
 
-	c.JSON(http.StatusOK, categories)
+	categories, err := h.usecase.GetAllCategories(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewCategoryTree(categories))
+}
+
+func (h *CategoryHandler) GetCategoryChildren(c *gin.Context) {
+	h.getRelatives(c, h.usecase.GetCategoryChildren)
+}
+
+func (h *CategoryHandler) GetCategoryAncestors(c *gin.Context) {
+	h.getRelatives(c, h.usecase.GetCategoryAncestors)
+}
+
+func (h *CategoryHandler) GetCategoryDescendants(c *gin.Context) {
+	h.getRelatives(c, h.usecase.GetCategoryDescendants)
+}
+
+// getRelatives responds with the categories load finds for the category in
+// the path.
+func (h *CategoryHandler) getRelatives(c *gin.Context, load func(ctx context.Context, id uint) ([]entity.Category, error)) {
+	id, err := parseID(c, "category")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if h.listNotModified(c) {
+		return
+	}
+
+	categories, err := load(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewCategoryResponses(categories))
+}
+
+// listNotModified answers a conditional request for a view of the category
+// list, which changes whenever any category does.
+func (h *CategoryHandler) listNotModified(c *gin.Context) bool {
+	stats, err := h.usecase.GetCategoryStats(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return true
+	}
+	return h.opts.notModified(c, listETag(stats), stats.LastModified)
 }
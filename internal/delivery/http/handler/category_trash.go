@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reinhardjs/dot-backend-test/internal/delivery/http/dto"
+)
+
+func (h *CategoryHandler) GetDeletedCategories(c *gin.Context) {
+	page, pageSize, err := parsePage(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	categories, total, err := h.usecase.GetDeletedCategories(c.Request.Context(), page, pageSize)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newPageResponse(c, dto.NewDeletedCategoryResponses(categories), page, pageSize, total))
+}
+
+func (h *CategoryHandler) RestoreCategory(c *gin.Context) {
+	id, err := parseID(c, "category")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	category, err := h.usecase.RestoreCategory(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	setETag(c, category.Version)
+	c.JSON(http.StatusOK, dto.NewCategoryResponse(*category))
+}
+
+func (h *CategoryHandler) PurgeCategory(c *gin.Context) {
+	id, err := parseID(c, "category")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.usecase.PurgeCategory(c.Request.Context(), id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category permanently deleted"})
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reinhardjs/dot-backend-test/internal/domain/repository"
+)
+
+// Options configures the HTTP semantics shared by the resource handlers.
+type Options struct {
+	// RequireIfMatch rejects PUT, PATCH and DELETE requests without If-Match.
+	RequireIfMatch bool
+	// CacheControl is sent with every successful or not modified GET
+	// response. Empty omits the header.
+	CacheControl string
+}
+
+// notModified sets the validators and caching headers of a GET response and
+// reports whether the copy the client already has is still current, in which
+// case it has written 304 Not Modified and the handler must stop. As required
+// by RFC 7232, If-Modified-Since is ignored when If-None-Match is present.
+func (o Options) notModified(c *gin.Context, etag string, lastModified time.Time) bool {
+	c.Header("ETag", etag)
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if o.CacheControl != "" {
+		c.Header("Cache-Control", o.CacheControl)
+	}
+
+	if header := c.GetHeader("If-None-Match"); header != "" {
+		if !etagMatches(header, etag) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(c.GetHeader("If-Modified-Since"))
+		// Last-Modified has a resolution of one second.
+		if err != nil || lastModified.IsZero() || lastModified.Truncate(time.Second).After(since) {
+			return false
+		}
+	}
+
+	c.Status(http.StatusNotModified)
+	c.Writer.WriteHeaderNow()
+	return true
+}
+
+// etagMatches compares the tags listed in If-None-Match with etag using the
+// weak comparison, which ignores the W/ prefix.
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// listETag is the weak entity tag of a listing. It is derived from the stats
+// of the listing rather than its body, so it can be checked before the list
+// is loaded.
+func listETag(stats repository.ListStats) string {
+	return fmt.Sprintf(`W/"%d-%d"`, stats.Count, stats.LastModified.UnixNano())
+}
+
+func latest(times ...time.Time) time.Time {
+	var t time.Time
+	for _, candidate := range times {
+		if candidate.After(t) {
+			t = candidate
+		}
+	}
+	return t
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotModified(t *testing.T) {
+	lastModified := time.Date(2024, 3, 14, 12, 0, 0, 500, time.UTC)
+	opts := Options{CacheControl: "public, max-age=60"}
+
+	serve := func(header, value string) *httptest.ResponseRecorder {
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.GET("/", func(c *gin.Context) {
+			if opts.notModified(c, `"3"`, lastModified) {
+				return
+			}
+			c.String(http.StatusOK, "body")
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+	assert.Equal(t, "Thu, 14 Mar 2024 12:00:00 GMT", w.Header().Get("Last-Modified"))
+	assert.Equal(t, "public, max-age=60", w.Header().Get("Cache-Control"))
+
+	tests := []struct {
+		header, value string
+		want          int
+	}{
+		{"If-None-Match", `"3"`, http.StatusNotModified},
+		{"If-None-Match", `W/"3"`, http.StatusNotModified},
+		{"If-None-Match", `"1", "3"`, http.StatusNotModified},
+		{"If-None-Match", `*`, http.StatusNotModified},
+		{"If-None-Match", `"2"`, http.StatusOK},
+		{"If-Modified-Since", "Thu, 14 Mar 2024 12:00:00 GMT", http.StatusNotModified},
+		{"If-Modified-Since", "Thu, 14 Mar 2024 13:00:00 GMT", http.StatusNotModified},
+		{"If-Modified-Since", "Thu, 14 Mar 2024 11:59:59 GMT", http.StatusOK},
+		{"If-Modified-Since", "yesterday", http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := serve(tt.header, tt.value)
+		assert.Equal(t, tt.want, w.Code, tt.header+": "+tt.value)
+		if tt.want == http.StatusNotModified {
+			assert.Empty(t, w.Body.String())
+			assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+)
+
+// etag is the strong entity tag of a resource at the given version. Tags of
+// representations that embed other resources append their versions, e.g.
+// "3-7" for version 3 of a product embedding version 7 of its category.
+func etag(version uint, embedded ...uint) string {
+	tag := strconv.FormatUint(uint64(version), 10)
+	for _, v := range embedded {
+		tag += "-" + strconv.FormatUint(uint64(v), 10)
+	}
+	return `"` + tag + `"`
+}
+
+// ifMatch holds the versions listed in an If-Match request header.
+type ifMatch struct {
+	present  bool
+	any      bool
+	versions []uint
+}
+
+// parseIfMatch reads the If-Match header. When required is set a request
+// without it is rejected.
+func parseIfMatch(c *gin.Context, required bool) (ifMatch, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		if required {
+			return ifMatch{}, errors.PreconditionRequired("this request must be conditional, send If-Match with the ETag of the resource")
+		}
+		return ifMatch{}, nil
+	}
+
+	m := ifMatch{present: true}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			m.any = true
+			continue
+		}
+		// Weak tags never match under the strong comparison If-Match uses,
+		// and unknown tags never match either.
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		version, _, _ := strings.Cut(tag[1:len(tag)-1], "-")
+		if v, err := strconv.ParseUint(version, 10, 32); err == nil {
+			m.versions = append(m.versions, uint(v))
+		}
+	}
+	return m, nil
+}
+
+// check verifies the header against the current version of the resource.
+func (m ifMatch) check(current uint) error {
+	if !m.present || m.any || slices.Contains(m.versions, current) {
+		return nil
+	}
+	return errors.PreconditionFailed("If-Match does not match the current ETag %s", etag(current))
+}
+
+func setETag(c *gin.Context, version uint, embedded ...uint) {
+	c.Header("ETag", etag(version, embedded...))
+}
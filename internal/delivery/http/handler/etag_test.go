@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func ifMatchContext(header string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest("PUT", "/", nil)
+	if header != "" {
+		c.Request.Header.Set("If-Match", header)
+	}
+	return c
+}
+
+func TestIfMatch(t *testing.T) {
+	// Missing headers are rejected only when required
+	_, err := parseIfMatch(ifMatchContext(""), true)
+	assert.Equal(t, errors.KindPreconditionRequired, errors.KindOf(err))
+
+	match, err := parseIfMatch(ifMatchContext(""), false)
+	assert.NoError(t, err)
+	assert.NoError(t, match.check(3))
+
+	tests := []struct {
+		header  string
+		current uint
+		ok      bool
+	}{
+		{`"3"`, 3, true},
+		{`"2"`, 3, false},
+		{`"1", "3"`, 3, true},
+		{`*`, 3, true},
+		{`"3-7"`, 3, true},
+		{`W/"3"`, 3, false},
+		{`3`, 3, false},
+		{`"abc"`, 3, false},
+	}
+	for _, tt := range tests {
+		match, err := parseIfMatch(ifMatchContext(tt.header), true)
+		assert.NoError(t, err, tt.header)
+		err = match.check(tt.current)
+		if tt.ok {
+			assert.NoError(t, err, tt.header)
+		} else {
+			assert.Equal(t, errors.KindPreconditionFailed, errors.KindOf(err), tt.header)
+		}
+	}
+
+	assert.Equal(t, `"3"`, etag(3))
+	assert.Equal(t, `"3-7"`, etag(3, 7))
+}
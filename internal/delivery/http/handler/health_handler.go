@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reinhardjs/dot-backend-test/internal/infrastructure/cache"
+)
+
+type HealthHandler struct {
+	cache cache.Cache
+}
+
+func NewHealthHandler(cache cache.Cache) *HealthHandler {
+	return &HealthHandler{cache: cache}
+}
+
+// Health reports "degraded" while the cache circuit breaker is not closed.
+// The service keeps serving from the database in that case, so the status
+// code stays 200.
+func (h *HealthHandler) Health(c *gin.Context) {
+	status := "ok"
+	cacheStatus := gin.H{"state": "closed"}
+
+	if breaker, ok := h.cache.(*cache.CircuitBreaker); ok {
+		state := breaker.State()
+		cacheStatus["state"] = state.String()
+		if state != cache.StateClosed {
+			status = "degraded"
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": status, "cache": cacheStatus})
+}
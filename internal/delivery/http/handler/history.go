@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reinhardjs/dot-backend-test/internal/delivery/http/dto"
+	"github.com/reinhardjs/dot-backend-test/internal/domain/entity"
+	"github.com/reinhardjs/dot-backend-test/internal/domain/repository"
+)
+
+func (h *ProductHandler) GetProductHistory(c *gin.Context) {
+	getHistory(c, "product", h.usecase.GetProductHistory)
+}
+
+func (h *CategoryHandler) GetCategoryHistory(c *gin.Context) {
+	getHistory(c, "category", h.usecase.GetCategoryHistory)
+}
+
+// getHistory responds with a page of the audit entries load finds for the
+// record in the path, optionally only those that changed the field query
+// parameter.
+func getHistory(c *gin.Context, name string, load func(ctx context.Context, query repository.AuditQuery) ([]entity.AuditEntry, int64, error)) {
+	id, err := parseID(c, name)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	query := repository.AuditQuery{EntityID: id, Field: strings.TrimSpace(c.Query("field"))}
+	if query.Page, query.PageSize, err = parsePage(c); err != nil {
+		c.Error(err)
+		return
+	}
+
+	entries, total, err := load(c.Request.Context(), query)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newPageResponse(c, dto.NewAuditEntryResponses(entries), query.Page, query.PageSize, total))
+}
@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reinhardjs/dot-backend-test/internal/domain/repository"
+	"github.com/reinhardjs/dot-backend-test/pkg/cursor"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+)
+
+type PageMeta struct {
+	Page       int   `json:"page"`
+	PageSize   int   `json:"page_size"`
+	Total      int64 `json:"total"`
+	TotalPages int   `json:"total_pages"`
+}
+
+type PageLinks struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+type PageResponse struct {
+	Data  interface{} `json:"data"`
+	Meta  PageMeta    `json:"meta"`
+	Links PageLinks   `json:"links"`
+}
+
+func newPageResponse(c *gin.Context, data interface{}, page, pageSize int, total int64) PageResponse {
+	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
+
+	links := PageLinks{Self: pageURL(c, page)}
+	if page < totalPages {
+		links.Next = pageURL(c, page+1)
+	}
+	if page > 1 {
+		prev := page - 1
+		if prev > totalPages && totalPages > 0 {
+			prev = totalPages
+		}
+		links.Prev = pageURL(c, prev)
+	}
+
+	return PageResponse{
+		Data: data,
+		Meta: PageMeta{
+			Page:       page,
+			PageSize:   pageSize,
+			Total:      total,
+			TotalPages: totalPages,
+		},
+		Links: links,
+	}
+}
+
+func pageURL(c *gin.Context, page int) string {
+	u := url.URL{Path: c.Request.URL.Path}
+	query := c.Request.URL.Query()
+	query.Set("page", strconv.Itoa(page))
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func parsePage(c *gin.Context) (int, int, error) {
+	page, err := queryInt(c, "page", 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	pageSize, err := queryInt(c, "page_size", repository.DefaultPageSize)
+	if err != nil {
+		return 0, 0, err
+	}
+	if page < 1 {
+		return 0, 0, errors.InvalidField("page", "page must be greater than 0")
+	}
+	if pageSize < 1 {
+		return 0, 0, errors.InvalidField("page_size", "page_size must be greater than 0")
+	}
+	page, pageSize = repository.NormalizePage(page, pageSize)
+	return page, pageSize, nil
+}
+
+func queryInt(c *gin.Context, key string, def int) (int, error) {
+	raw, ok := c.GetQuery(key)
+	if !ok || raw == "" {
+		return def, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, errors.InvalidField(key, "invalid %s: %q", key, raw)
+	}
+	return value, nil
+}
+
+func queryUint(c *gin.Context, key string) (*uint, error) {
+	raw, ok := c.GetQuery(key)
+	if !ok || raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil {
+		return nil, errors.InvalidField(key, "invalid %s: %q", key, raw)
+	}
+	v := uint(value)
+	return &v, nil
+}
+
+func queryBool(c *gin.Context, key string) (bool, error) {
+	raw, ok := c.GetQuery(key)
+	if !ok || raw == "" {
+		return false, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, errors.InvalidField(key, "invalid %s: %q", key, raw)
+	}
+	return value, nil
+}
+
+func queryFloat(c *gin.Context, key string) (*float64, error) {
+	raw, ok := c.GetQuery(key)
+	if !ok || raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, errors.InvalidField(key, "invalid %s: %q", key, raw)
+	}
+	return &value, nil
+}
+
+// queryTime accepts either an RFC 3339 timestamp or a plain date. When
+// endOfDay is set, a plain date is expanded to the last instant of that day so
+// that "to" bounds are inclusive.
+func queryTime(c *gin.Context, key string, endOfDay bool) (*time.Time, error) {
+	raw, ok := c.GetQuery(key)
+	if !ok || raw == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return nil, errors.InvalidField(key, "invalid %s: %q, expected RFC 3339 timestamp or YYYY-MM-DD", key, raw)
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return &t, nil
+}
+
+type CursorPageResponse struct {
+	Data       interface{} `json:"data"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Links      PageLinks   `json:"links"`
+}
+
+// isCursorRequest reports whether the client asked for keyset pagination. An
+// empty cursor parameter starts a scan from the beginning.
+func isCursorRequest(c *gin.Context) bool {
+	_, ok := c.GetQuery("cursor")
+	return ok
+}
+
+func cursorSort(sort []repository.SortField) (repository.SortField, error) {
+	if len(sort) > 1 {
+		return repository.SortField{}, errors.InvalidField("sort", "cursor pagination supports a single sort field")
+	}
+	if len(sort) == 0 {
+		return repository.SortField{Field: "id"}, nil
+	}
+	return sort[0], nil
+}
+
+// decodeCursor validates the cursor query parameter against the sort order
+// and filters of the current request and returns the position to continue
+// from, or nil for the first page.
+func decodeCursor(c *gin.Context, codec *cursor.Codec, sort repository.SortField) (*repository.Keyset, error) {
+	token := c.Query("cursor")
+	if token == "" {
+		return nil, nil
+	}
+
+	cur, err := codec.Decode(token)
+	if err != nil {
+		return nil, errors.InvalidField("cursor", "%v", err)
+	}
+	if cur.Sort != sort.Field || cur.Desc != sort.Desc || cur.Filter != filterFingerprint(c) {
+		return nil, errors.InvalidField("cursor", "cursor does not match the requested sort or filters")
+	}
+
+	value, err := repository.ParseKeysetValue(cur.Sort, cur.Value)
+	if err != nil {
+		return nil, errors.InvalidField("cursor", "%v", cursor.ErrInvalidCursor)
+	}
+	return &repository.Keyset{Value: value, ID: cur.ID}, nil
+}
+
+func encodeCursor(c *gin.Context, codec *cursor.Codec, sort repository.SortField, keyset repository.Keyset) (string, error) {
+	cur := cursor.Cursor{
+		Sort:   sort.Field,
+		Desc:   sort.Desc,
+		Filter: filterFingerprint(c),
+		ID:     keyset.ID,
+	}
+	if keyset.Value != nil {
+		value, err := json.Marshal(keyset.Value)
+		if err != nil {
+			return "", err
+		}
+		cur.Value = value
+	}
+	return codec.Encode(cur)
+}
+
+func newCursorPageResponse(c *gin.Context, data interface{}, nextCursor string) CursorPageResponse {
+	links := PageLinks{Self: c.Request.URL.RequestURI()}
+	if nextCursor != "" {
+		u := url.URL{Path: c.Request.URL.Path}
+		query := c.Request.URL.Query()
+		query.Set("cursor", nextCursor)
+		u.RawQuery = query.Encode()
+		links.Next = u.String()
+	}
+	return CursorPageResponse{Data: data, NextCursor: nextCursor, Links: links}
+}
+
+// filterFingerprint hashes every query parameter that narrows the result set,
+// i.e. everything except the paging, ordering and presentation parameters.
+func filterFingerprint(c *gin.Context) string {
+	query := c.Request.URL.Query()
+	for _, key := range []string{"cursor", "include", "page", "page_size", "sort"} {
+		query.Del(key)
+	}
+	if len(query) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(query.Encode()))
+	return hex.EncodeToString(sum[:8])
+}
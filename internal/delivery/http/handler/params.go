@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+)
+
+func parseID(c *gin.Context, resource string) (uint, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || id == 0 {
+		return 0, errors.InvalidField("id", "invalid %s ID", resource)
+	}
+	return uint(id), nil
+}
+
+// parseInclude reports which related resources were requested through the
+// comma-separated include query parameter. Unknown names are rejected.
+func parseInclude(c *gin.Context, allowed ...string) (map[string]bool, error) {
+	include := make(map[string]bool)
+	for _, name := range strings.Split(c.Query("include"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(allowed, name) {
+			return nil, errors.InvalidField("include", "unsupported include %q", name)
+		}
+		include[name] = true
+	}
+	return include, nil
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reinhardjs/dot-backend-test/internal/delivery/http/dto"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+	"github.com/reinhardjs/dot-backend-test/pkg/jsonpatch"
+)
+
+// bindPatch applies the patch in the request body to current, the request
+// representation of the stored resource, and decodes and validates the result
+// into req. Plain application/json bodies are treated as merge patches.
+func bindPatch(c *gin.Context, current, req interface{}) error {
+	patch, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return errors.Validation("failed to read request body").WithCode("invalid_body")
+	}
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+
+	var patched []byte
+	switch contentType := c.ContentType(); contentType {
+	case jsonpatch.MergePatchContentType, "application/json":
+		patched, err = jsonpatch.MergePatch(doc, patch)
+	case jsonpatch.JSONPatchContentType:
+		patched, err = jsonpatch.Apply(doc, patch)
+	default:
+		return errors.UnsupportedMediaType("unsupported patch format %q, use %s or %s",
+			contentType, jsonpatch.MergePatchContentType, jsonpatch.JSONPatchContentType)
+	}
+	switch {
+	case errors.Is(err, jsonpatch.ErrTestFailed):
+		return errors.Wrap(errors.KindConflict, err, "%v", err).WithCode("patch_test_failed")
+	case err != nil:
+		return errors.Wrap(errors.KindValidation, err, "%v", err).WithCode("invalid_patch")
+	}
+
+	return dto.Decode(bytes.NewReader(patched), req)
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reinhardjs/dot-backend-test/internal/delivery/http/dto"
+	"github.com/reinhardjs/dot-backend-test/internal/usecase"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+)
+
+// ProductAction serves the custom methods on the product collection,
+// POST /products:batch and POST /products:import. Gin cannot route a
+// literal ":" inside a segment, so they share the route /products:action
+// and the action includes the colon.
+func (h *ProductHandler) ProductAction(c *gin.Context) {
+	switch c.Param("action") {
+	case ":batch":
+		h.BatchProducts(c)
+	case ":import":
+		h.ImportProducts(c)
+	default:
+		c.Error(errors.NotFound("unknown product action %q", c.Param("action")))
+	}
+}
+
+// BatchProducts creates, updates and deletes products in a single request.
+// In atomic mode (the default) the first invalid or failing operation fails
+// the whole request; in best-effort mode every operation reports its own
+// outcome.
+func (h *ProductHandler) BatchProducts(c *gin.Context) {
+	var req dto.ProductBatchRequest
+	if err := dto.Bind(c, &req); err != nil {
+		c.Error(err)
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = dto.BatchModeAtomic
+	}
+	atomic := req.Mode == dto.BatchModeAtomic
+
+	response := dto.ProductBatchResponse{Mode: req.Mode, Results: make([]dto.ProductBatchResult, len(req.Operations))}
+	var ops []usecase.ProductOperation
+	var indexes []int
+	var invalid []errors.FieldError
+	for i, op := range req.Operations {
+		response.Results[i] = dto.ProductBatchResult{Index: i, Op: op.Op}
+		if err := dto.Validate(&op); err != nil {
+			if atomic {
+				invalid = append(invalid, operationFields(i, err)...)
+			} else {
+				failOperation(c, &response.Results[i], operationError(i, err))
+			}
+			continue
+		}
+		ops = append(ops, productOperation(op))
+		indexes = append(indexes, i)
+	}
+	if len(invalid) > 0 {
+		c.Error(errors.InvalidFields(invalid...))
+		return
+	}
+
+	results, err := h.usecase.BatchProducts(c.Request.Context(), ops, atomic)
+	if err != nil {
+		var opErr *usecase.OperationError
+		if errors.As(err, &opErr) {
+			err = operationError(indexes[opErr.Index], opErr.Err)
+		}
+		c.Error(err)
+		return
+	}
+
+	for n, result := range results {
+		item := &response.Results[indexes[n]]
+		if result.Err != nil {
+			failOperation(c, item, operationError(indexes[n], result.Err))
+			continue
+		}
+		switch item.Op {
+		case usecase.OpCreate:
+			item.Status = http.StatusCreated
+		case usecase.OpUpdate:
+			item.Status = http.StatusOK
+		case usecase.OpDelete:
+			item.Status = http.StatusNoContent
+		}
+		if result.Product != nil {
+			product := dto.NewProductResponse(*result.Product, false)
+			item.Product = &product
+		}
+	}
+
+	for _, item := range response.Results {
+		if item.Error != nil {
+			response.Failed++
+		} else {
+			response.Succeeded++
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func failOperation(c *gin.Context, item *dto.ProductBatchResult, err error) {
+	problem := errors.NewProblem(err, c.Request.URL.Path)
+	if problem.Status >= http.StatusInternalServerError {
+		log.Printf("%s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+	}
+	item.Status = problem.Status
+	item.Error = &problem
+}
+
+func productOperation(op dto.ProductBatchOperation) usecase.ProductOperation {
+	operation := usecase.ProductOperation{Op: op.Op, ID: op.ID, Version: op.Version}
+	if op.Product != nil {
+		operation.Product = op.Product.ToEntity()
+		operation.Apply = op.Product.ApplyTo
+	}
+	return operation
+}
+
+// operationError points err at the operation with the given index, so that
+// clients can tell which operation of a batch it is about.
+func operationError(index int, err error) error {
+	var domainErr *errors.Error
+	if !errors.As(err, &domainErr) || domainErr.Kind == errors.KindInternal {
+		return err
+	}
+	wrapped := *domainErr
+	wrapped.Message = fmt.Sprintf("operations[%d]: %s", index, domainErr.Message)
+	wrapped.Fields = operationFields(index, domainErr)
+	return &wrapped
+}
+
+func operationFields(index int, err error) []errors.FieldError {
+	prefix := fmt.Sprintf("operations[%d]", index)
+	var domainErr *errors.Error
+	if !errors.As(err, &domainErr) {
+		return nil
+	}
+	if len(domainErr.Fields) == 0 && domainErr.Kind == errors.KindValidation {
+		return []errors.FieldError{{Field: prefix, Message: domainErr.Message}}
+	}
+
+	var fields []errors.FieldError
+	for _, field := range domainErr.Fields {
+		fields = append(fields, errors.FieldError{Field: prefix + "." + field.Field, Message: field.Message})
+	}
+	return fields
+}
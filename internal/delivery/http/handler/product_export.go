@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reinhardjs/dot-backend-test/internal/domain/entity"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+	"github.com/reinhardjs/dot-backend-test/pkg/export"
+)
+
+var productExportColumns = []string{
+	"id", "external_key", "name", "price", "category_id", "category_name", "version", "created_at", "updated_at",
+}
+
+// ExportProducts streams every product matching the list filters as a CSV,
+// NDJSON or XLSX download. Paging parameters are ignored.
+func (h *ProductHandler) ExportProducts(c *gin.Context) {
+	format := c.DefaultQuery("format", export.CSV)
+	if format != export.CSV && format != export.NDJSON && format != export.XLSX {
+		c.Error(errors.InvalidField("format", "format must be one of csv, ndjson, xlsx"))
+		return
+	}
+	query, err := parseProductQuery(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	filename := fmt.Sprintf("products-%s.%s", time.Now().UTC().Format("2006-01-02"), format)
+	c.Header("Content-Type", export.ContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	w, err := export.NewWriter(c.Writer, format, productExportColumns)
+	if err == nil {
+		err = h.usecase.ExportProducts(c.Request.Context(), query, func(p entity.Product) error {
+			return w.Write(p.ID, p.ExternalKey, p.Name, p.Price, p.CategoryID, p.Category.Name, p.Version, p.CreatedAt, p.UpdatedAt)
+		})
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		// Once the download has started its status can no longer be changed.
+		// Cut the connection so the client sees a failed transfer instead of
+		// a truncated file that looks complete.
+		if c.Writer.Written() {
+			log.Printf("%s %s: export aborted: %v", c.Request.Method, c.Request.URL.Path, err)
+			c.Abort()
+			abortStream(c)
+			return
+		}
+		c.Writer.Header().Del("Content-Type")
+		c.Writer.Header().Del("Content-Disposition")
+		c.Error(err)
+	}
+}
+
+// abortStream closes the connection of a response that has already started,
+// without ending it properly.
+func abortStream(c *gin.Context) {
+	// The gin writer asserts that the connection can be hijacked, so ask the
+	// writer it wraps.
+	var w http.ResponseWriter = c.Writer
+	if unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter }); ok {
+		w = unwrapper.Unwrap()
+	}
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		// HTTP/2 streams cannot be hijacked, but net/http resets them when
+		// the handler panics with ErrAbortHandler.
+		panic(http.ErrAbortHandler)
+	}
+	conn.Close()
+}
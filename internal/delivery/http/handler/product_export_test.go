@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reinhardjs/dot-backend-test/internal/domain/entity"
+	"github.com/reinhardjs/dot-backend-test/internal/domain/repository"
+	"github.com/reinhardjs/dot-backend-test/internal/usecase"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingExport streams rows products and then fails.
+type failingExport struct {
+	usecase.ProductUsecase
+	rows int
+}
+
+func (u failingExport) ExportProducts(ctx context.Context, query repository.ProductQuery, fn func(product entity.Product) error) error {
+	for i := 1; i <= u.rows; i++ {
+		if err := fn(entity.Product{ID: uint(i), Name: fmt.Sprintf("Product %d", i), Price: 9.5}); err != nil {
+			return err
+		}
+	}
+	return errors.Unavailable(io.ErrUnexpectedEOF, "failed to read products")
+}
+
+func TestExportProductsFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newServer := func(rows int) *httptest.Server {
+		router := gin.New()
+		router.Use(errors.ErrorHandler())
+		router.GET("/products/export", NewProductHandler(failingExport{rows: rows}, nil, Options{}).ExportProducts)
+		return httptest.NewServer(router)
+	}
+
+	t.Run("Fails The Transfer Once Streaming", func(t *testing.T) {
+		server := newServer(10000)
+		defer server.Close()
+
+		resp, err := http.Get(server.URL + "/products/export")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		_, err = io.ReadAll(resp.Body)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+
+	t.Run("Reports Errors Before Streaming", func(t *testing.T) {
+		server := newServer(1)
+		defer server.Close()
+
+		resp, err := http.Get(server.URL + "/products/export")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("Content-Disposition"))
+	})
+}
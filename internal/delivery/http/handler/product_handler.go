@@ -2,89 +2,154 @@ package handler
 
 import (
 	"net/http"
-	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/reinhardjs/dot-backend-test/internal/delivery/http/dto"
 	"github.com/reinhardjs/dot-backend-test/internal/domain/entity"
+	"github.com/reinhardjs/dot-backend-test/internal/domain/repository"
 	"github.com/reinhardjs/dot-backend-test/internal/usecase"
+	"github.com/reinhardjs/dot-backend-test/pkg/cursor"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
 )
 
 type ProductHandler struct {
 	usecase usecase.ProductUsecase
+	cursors *cursor.Codec
+	opts    Options
 }
 
-func NewProductHandler(usecase usecase.ProductUsecase) *ProductHandler {
-	return &ProductHandler{usecase: usecase}
+func NewProductHandler(usecase usecase.ProductUsecase, cursors *cursor.Codec, opts Options) *ProductHandler {
+	return &ProductHandler{usecase: usecase, cursors: cursors, opts: opts}
 }
 
 func (h *ProductHandler) CreateProduct(c *gin.Context) {
-	var product entity.Product
-	if err := c.ShouldBindJSON(&product); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req dto.ProductRequest
+	if err := dto.Bind(c, &req); err != nil {
+		c.Error(err)
 		return
 	}
+	product := req.ToEntity()
 
-	if product.Name == "" || product.Price < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product data: name cannot be empty and price must be non-negative"})
+	if err := h.usecase.CreateProduct(c.Request.Context(), &product); err != nil {
+		c.Error(err)
 		return
 	}
 
-	if err := h.usecase.CreateProduct(&product); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusCreated, product)
+	setETag(c, product.Version)
+	c.JSON(http.StatusCreated, dto.NewProductResponse(product, false))
 }
 
 func (h *ProductHandler) GetProduct(c *gin.Context) {
-	id, _ := strconv.Atoi(c.Param("id"))
-	product, err := h.usecase.GetProductByID(uint(id))
+	id, err := parseID(c, "product")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	include, err := parseInclude(c, "category")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	product, err := h.usecase.GetProductByID(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, product)
+	tag, lastModified := etag(product.Version), product.UpdatedAt
+	if include["category"] {
+		tag = etag(product.Version, product.Category.Version)
+		lastModified = latest(product.UpdatedAt, product.Category.UpdatedAt)
+	}
+	if h.opts.notModified(c, tag, lastModified) {
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewProductResponse(*product, include["category"]))
 }
 
 func (h *ProductHandler) UpdateProduct(c *gin.Context) {
-	id, _ := strconv.Atoi(c.Param("id"))
-	
-	// Check if product exists
-	_, err := h.usecase.GetProductByID(uint(id))
+	h.updateProduct(c, func(current entity.Product, req *dto.ProductRequest) error {
+		return dto.Bind(c, req)
+	})
+}
+
+func (h *ProductHandler) PatchProduct(c *gin.Context) {
+	h.updateProduct(c, func(current entity.Product, req *dto.ProductRequest) error {
+		return bindPatch(c, dto.NewProductRequest(current), req)
+	})
+}
+
+// updateProduct loads the product, binds its new representation with bind and
+// writes only the fields that changed.
+func (h *ProductHandler) updateProduct(c *gin.Context, bind func(current entity.Product, req *dto.ProductRequest) error) {
+	id, err := parseID(c, "product")
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		c.Error(err)
+		return
+	}
+	match, err := parseIfMatch(c, h.opts.RequireIfMatch)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	product, err := h.usecase.GetProductForUpdate(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if err := match.check(product.Version); err != nil {
+		c.Error(err)
 		return
 	}
 
-	var product entity.Product
-	if err := c.ShouldBindJSON(&product); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var req dto.ProductRequest
+	if err := bind(*product, &req); err != nil {
+		c.Error(err)
 		return
 	}
 
-	product.ID = uint(id)
-	if err := h.usecase.UpdateProduct(&product); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	fields := req.ApplyTo(product)
+	if err := h.usecase.UpdateProduct(c.Request.Context(), product, fields...); err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, product)
+	setETag(c, product.Version)
+	c.JSON(http.StatusOK, dto.NewProductResponse(*product, false))
 }
 
 func (h *ProductHandler) DeleteProduct(c *gin.Context) {
-	id, _ := strconv.Atoi(c.Param("id"))
-	
-	// Check if product exists
-	_, err := h.usecase.GetProductByID(uint(id))
+	id, err := parseID(c, "product")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	match, err := parseIfMatch(c, h.opts.RequireIfMatch)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		c.Error(err)
 		return
 	}
 
-	if err := h.usecase.DeleteProduct(uint(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var version uint
+	if match.present {
+		product, err := h.usecase.GetProductForUpdate(c.Request.Context(), id)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if err := match.check(product.Version); err != nil {
+			c.Error(err)
+			return
+		}
+		version = product.Version
+	}
+
+	if err := h.usecase.DeleteProduct(c.Request.Context(), id, version); err != nil {
+		c.Error(err)
 		return
 	}
 
@@ -92,11 +157,117 @@ func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 }
 
 func (h *ProductHandler) GetAllProducts(c *gin.Context) {
-	products, err := h.usecase.GetAllProducts()
+	query, err := parseProductQuery(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	include, err := parseInclude(c, "category")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Moving a subcategory changes which products match.
+	includeCategories := include["category"] || query.IncludeSubcategories
+	stats, err := h.usecase.GetProductStats(c.Request.Context(), query, includeCategories)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
+		return
+	}
+	if h.opts.notModified(c, listETag(stats), stats.LastModified) {
+		return
+	}
+
+	if isCursorRequest(c) {
+		h.getProductsByCursor(c, query, include["category"])
+		return
+	}
+
+	products, total, err := h.usecase.GetAllProducts(c.Request.Context(), query)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newPageResponse(c, dto.NewProductResponses(products, include["category"]), query.Page, query.PageSize, total))
+}
+
+func (h *ProductHandler) getProductsByCursor(c *gin.Context, query repository.ProductQuery, includeCategory bool) {
+	sort, err := cursorSort(query.Sort)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	after, err := decodeCursor(c, h.cursors, sort)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, products)
+	// Fetch one extra row to find out whether another page exists.
+	pageSize := query.PageSize
+	query.Sort = []repository.SortField{sort}
+	query.PageSize = pageSize + 1
+	products, err := h.usecase.GetProductsAfter(c.Request.Context(), query, after)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var nextCursor string
+	if len(products) > pageSize {
+		products = products[:pageSize]
+		keyset := repository.ProductKeyset(products[pageSize-1], sort.Field)
+		if nextCursor, err = encodeCursor(c, h.cursors, sort, keyset); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, newCursorPageResponse(c, dto.NewProductResponses(products, includeCategory), nextCursor))
+}
+
+func parseProductQuery(c *gin.Context) (repository.ProductQuery, error) {
+	var query repository.ProductQuery
+	var err error
+
+	if query.Page, query.PageSize, err = parsePage(c); err != nil {
+		return query, err
+	}
+	if query.CategoryID, err = queryUint(c, "category_id"); err != nil {
+		return query, err
+	}
+	if query.IncludeSubcategories, err = queryBool(c, "include_subcategories"); err != nil {
+		return query, err
+	}
+	if query.IncludeSubcategories && query.CategoryID == nil {
+		return query, errors.InvalidField("include_subcategories", "include_subcategories requires category_id")
+	}
+	if query.MinPrice, err = queryFloat(c, "min_price"); err != nil {
+		return query, err
+	}
+	if query.MaxPrice, err = queryFloat(c, "max_price"); err != nil {
+		return query, err
+	}
+	if query.MinPrice != nil && query.MaxPrice != nil && *query.MinPrice > *query.MaxPrice {
+		return query, errors.InvalidField("min_price", "min_price must not be greater than max_price")
+	}
+	query.Name = strings.TrimSpace(c.Query("name"))
+	if query.CreatedFrom, err = queryTime(c, "created_from", false); err != nil {
+		return query, err
+	}
+	if query.CreatedTo, err = queryTime(c, "created_to", true); err != nil {
+		return query, err
+	}
+	if query.UpdatedFrom, err = queryTime(c, "updated_from", false); err != nil {
+		return query, err
+	}
+	if query.UpdatedTo, err = queryTime(c, "updated_to", true); err != nil {
+		return query, err
+	}
+	if query.Sort, err = repository.ParseSort(c.Query("sort"), repository.ProductSortColumns); err != nil {
+		return query, err
+	}
+	return query, nil
 }
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reinhardjs/dot-backend-test/internal/delivery/http/dto"
+	"github.com/reinhardjs/dot-backend-test/internal/usecase"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+)
+
+// MaxImportSize bounds the size of an uploaded import file.
+const MaxImportSize = 10 << 20
+
+// importFormats maps the accepted content types to import formats.
+var importFormats = map[string]string{
+	"text/csv":             dto.FormatCSV,
+	"application/x-ndjson": dto.FormatNDJSON,
+	"application/ndjson":   dto.FormatNDJSON,
+}
+
+// ImportProducts upserts the products of a CSV or NDJSON file by external
+// key. With dry_run=true it only reports what it would do.
+func (h *ProductHandler) ImportProducts(c *gin.Context) {
+	format, ok := importFormats[c.ContentType()]
+	if !ok {
+		c.Error(errors.UnsupportedMediaType("Content-Type must be text/csv or application/x-ndjson"))
+		return
+	}
+
+	var opts usecase.ImportOptions
+	var err error
+	if opts.DryRun, err = queryBool(c, "dry_run"); err != nil {
+		c.Error(err)
+		return
+	}
+	if opts.CreateCategories, err = queryBool(c, "create_categories"); err != nil {
+		c.Error(err)
+		return
+	}
+
+	rows, err := dto.DecodeProductImport(http.MaxBytesReader(c.Writer, c.Request.Body, MaxImportSize), format)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	report, err := h.usecase.ImportProducts(c.Request.Context(), dto.NewProductImportRows(rows), opts)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewProductImportResponse(report))
+}
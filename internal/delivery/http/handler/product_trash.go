@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reinhardjs/dot-backend-test/internal/delivery/http/dto"
+)
+
+func (h *ProductHandler) GetDeletedProducts(c *gin.Context) {
+	page, pageSize, err := parsePage(c)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	products, total, err := h.usecase.GetDeletedProducts(c.Request.Context(), page, pageSize)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newPageResponse(c, dto.NewDeletedProductResponses(products), page, pageSize, total))
+}
+
+func (h *ProductHandler) RestoreProduct(c *gin.Context) {
+	id, err := parseID(c, "product")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	product, err := h.usecase.RestoreProduct(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	setETag(c, product.Version)
+	c.JSON(http.StatusOK, dto.NewProductResponse(*product, false))
+}
+
+func (h *ProductHandler) PurgeProduct(c *gin.Context) {
+	id, err := parseID(c, "product")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := h.usecase.PurgeProduct(c.Request.Context(), id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Product permanently deleted"})
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reinhardjs/dot-backend-test/pkg/audit"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	// ActorHeader names who makes the request. There is no authentication,
+	// so it is only trusted when a gateway in front of the service sets it.
+	ActorHeader = "X-Actor"
+
+	// Both end up in audit entries, whose columns hold 100 characters.
+	maxAuditValueLength = 100
+)
+
+// Audit attaches the actor and request ID of the request to its context, so
+// that writes made on its behalf can be audited. The actor is read from
+// X-Actor only if trustActor is set, and is anonymous otherwise. Requests
+// without a usable X-Request-ID get a random one, which is echoed in the
+// response.
+func Audit(trustActor bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" || len(requestID) > maxAuditValueLength {
+			requestID = newRequestID()
+		}
+		c.Header(RequestIDHeader, requestID)
+
+		ctx := audit.WithRequestID(c.Request.Context(), requestID)
+		if actor := c.GetHeader(ActorHeader); trustActor && actor != "" && len(actor) <= maxAuditValueLength {
+			ctx = audit.WithActor(ctx, actor)
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reinhardjs/dot-backend-test/pkg/audit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAudit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(trustActor bool) *gin.Engine {
+		router := gin.New()
+		router.Use(Audit(trustActor))
+		router.GET("/", func(c *gin.Context) {
+			ctx := c.Request.Context()
+			c.String(http.StatusOK, audit.Actor(ctx)+" "+audit.RequestID(ctx))
+		})
+		return router
+	}
+
+	get := func(router *gin.Engine, headers map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	trusted := newRouter(true)
+	w := get(trusted, map[string]string{ActorHeader: "alice", RequestIDHeader: "req-1"})
+	assert.Equal(t, "alice req-1", w.Body.String())
+	assert.Equal(t, "req-1", w.Header().Get(RequestIDHeader))
+
+	// Missing or oversized values fall back to defaults
+	w = get(trusted, map[string]string{RequestIDHeader: strings.Repeat("x", 101)})
+	requestID := w.Header().Get(RequestIDHeader)
+	assert.Len(t, requestID, 32)
+	assert.Equal(t, audit.Anonymous+" "+requestID, w.Body.String())
+
+	// The actor header is ignored unless it is trusted
+	w = get(newRouter(false), map[string]string{ActorHeader: "alice", RequestIDHeader: "req-2"})
+	assert.Equal(t, audit.Anonymous+" req-2", w.Body.String())
+}
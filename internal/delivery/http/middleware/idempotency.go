@@ -0,0 +1,238 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reinhardjs/dot-backend-test/internal/infrastructure/cache"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	idempotencyPollInterval  = 50 * time.Millisecond
+	idempotencyKeyPrefix     = "idempotency:"
+
+	// DefaultMaxBodySize bounds the bodies Idempotency reads for routes that
+	// take ordinary JSON requests.
+	DefaultMaxBodySize = 1 << 20
+)
+
+// replayedHeaders are the response headers stored along with the body.
+var replayedHeaders = []string{"Content-Type", "ETag", "Last-Modified", "Location"}
+
+type idempotentResponse struct {
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+}
+
+// Idempotency makes requests carrying an Idempotency-Key header safe to
+// retry. The first successful response for a key is stored for ttl and
+// replayed to later requests with the same key and body; reusing the key with
+// a different body is rejected with 422. Requests with the same key are
+// serialized through the cache's Locker, so a retry that arrives while the
+// original is still running waits for its response. The lock is renewed for
+// as long as the request runs and lapses lockTTL after a replica dies holding
+// it.
+//
+// The body is read up front to fingerprint it, and requests with a body
+// larger than maxBodySize are rejected with 413. Error responses are not
+// stored, so a request that failed can be retried with the same key.
+func Idempotency(store cache.Cache, ttl, lockTTL time.Duration, maxBodySize int64) gin.HandlerFunc {
+	locker, _ := store.(cache.Locker)
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.Error(errors.InvalidField(IdempotencyKeyHeader, "%s must be at most %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLength))
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBodySize))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				c.Error(errors.PayloadTooLarge("request body must not be larger than %d bytes", maxBytesErr.Limit))
+			} else {
+				c.Error(errors.Validation("failed to read request body").WithCode("invalid_body"))
+			}
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		storeKey := idempotencyStoreKey(c.Request.Method, c.Request.URL.Path, key)
+		fingerprint := idempotencyFingerprint(body)
+
+		for {
+			if response, ok := loadIdempotentResponse(ctx, store, storeKey); ok {
+				replay(c, response, fingerprint)
+				return
+			}
+			if locker == nil {
+				break
+			}
+
+			token, acquired, err := locker.Lock(ctx, storeKey, lockTTL)
+			if err != nil {
+				// Without the lock concurrent retries may both run, which is
+				// no worse than not supporting the header at all.
+				log.Printf("idempotency: lock %s: %v", storeKey, err)
+				break
+			}
+			if acquired {
+				defer locker.Unlock(context.WithoutCancel(ctx), storeKey, token)
+				defer keepLocked(context.WithoutCancel(ctx), locker, storeKey, token, lockTTL)()
+				// The previous holder may have stored its response just
+				// before releasing the lock.
+				if response, ok := loadIdempotentResponse(ctx, store, storeKey); ok {
+					replay(c, response, fingerprint)
+					return
+				}
+				break
+			}
+
+			select {
+			case <-ctx.Done():
+				c.Error(errors.Unavailable(ctx.Err(), "a request with the same %s is still in progress", IdempotencyKeyHeader))
+				c.Abort()
+				return
+			case <-time.After(idempotencyPollInterval):
+			}
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		if len(c.Errors) > 0 || !recorder.Written() || recorder.Status() >= http.StatusInternalServerError {
+			return
+		}
+
+		response := idempotentResponse{
+			Fingerprint: fingerprint,
+			Status:      recorder.Status(),
+			Header:      http.Header{},
+			Body:        recorder.body.Bytes(),
+		}
+		for _, name := range replayedHeaders {
+			if values := recorder.Header().Values(name); len(values) > 0 {
+				response.Header[name] = values
+			}
+		}
+
+		data, err := json.Marshal(response)
+		if err == nil {
+			err = store.Set(context.WithoutCancel(ctx), storeKey, data, ttl)
+		}
+		if err != nil {
+			log.Printf("idempotency: store %s: %v", storeKey, err)
+		}
+	}
+}
+
+// keepLocked extends the lock on key every half lockTTL until the returned
+// function is called, so requests outliving lockTTL, such as bulk imports,
+// stay serialized with their retries.
+func keepLocked(ctx context.Context, locker cache.Locker, key, token string, lockTTL time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(lockTTL / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			held, err := locker.Extend(ctx, key, token, lockTTL)
+			if err != nil {
+				log.Printf("idempotency: extend lock %s: %v", key, err)
+			} else if !held {
+				log.Printf("idempotency: lost lock %s", key)
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func replay(c *gin.Context, response idempotentResponse, fingerprint string) {
+	defer c.Abort()
+
+	if response.Fingerprint != fingerprint {
+		c.Error(errors.Unprocessable("%s has already been used with a different request body", IdempotencyKeyHeader).
+			WithCode("idempotency_key_reused"))
+		return
+	}
+
+	for name, values := range response.Header {
+		for _, value := range values {
+			c.Writer.Header().Add(name, value)
+		}
+	}
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Data(response.Status, response.Header.Get("Content-Type"), response.Body)
+}
+
+func loadIdempotentResponse(ctx context.Context, store cache.Cache, key string) (idempotentResponse, bool) {
+	var response idempotentResponse
+	data, err := store.Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, cache.ErrMiss) {
+			log.Printf("idempotency: load %s: %v", key, err)
+		}
+		return response, false
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		log.Printf("idempotency: decode %s: %v", key, err)
+		return response, false
+	}
+	return response, true
+}
+
+// idempotencyStoreKey scopes keys to the request path, so the same key may be
+// used against different endpoints.
+func idempotencyStoreKey(method, path, key string) string {
+	sum := sha256.Sum256([]byte(method + " " + path + " " + key))
+	return idempotencyKeyPrefix + hex.EncodeToString(sum[:])
+}
+
+func idempotencyFingerprint(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// responseRecorder copies everything written to the response into body.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
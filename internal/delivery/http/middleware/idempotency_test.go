@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/reinhardjs/dot-backend-test/internal/infrastructure/cache"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouterWithLockTTL := func(lockTTL time.Duration, handler gin.HandlerFunc) *gin.Engine {
+		router := gin.New()
+		router.Use(errors.ErrorHandler())
+		router.POST("/items", Idempotency(cache.NewMemoryCache(100, time.Hour), time.Hour, lockTTL, 64), handler)
+		return router
+	}
+	newRouter := func(handler gin.HandlerFunc) *gin.Engine {
+		return newRouterWithLockTTL(time.Second, handler)
+	}
+
+	post := func(router *gin.Engine, key, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/items", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Replays Stored Response", func(t *testing.T) {
+		var calls int32
+		router := newRouter(func(c *gin.Context) {
+			n := atomic.AddInt32(&calls, 1)
+			c.Header("ETag", `"1"`)
+			c.JSON(http.StatusCreated, gin.H{"id": n})
+		})
+
+		first := post(router, "key-1", `{"name":"a"}`)
+		second := post(router, "key-1", `{"name":"a"}`)
+
+		assert.Equal(t, int32(1), calls)
+		assert.Equal(t, http.StatusCreated, second.Code)
+		assert.Equal(t, first.Body.String(), second.Body.String())
+		assert.Equal(t, `"1"`, second.Header().Get("ETag"))
+		assert.Equal(t, "true", second.Header().Get(IdempotentReplayedHeader))
+		assert.Empty(t, first.Header().Get(IdempotentReplayedHeader))
+	})
+
+	t.Run("Rejects Key Reused With Different Body", func(t *testing.T) {
+		router := newRouter(func(c *gin.Context) {
+			c.JSON(http.StatusCreated, gin.H{"id": 1})
+		})
+
+		post(router, "key-1", `{"name":"a"}`)
+		w := post(router, "key-1", `{"name":"b"}`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), "idempotency_key_reused")
+	})
+
+	t.Run("Does Not Store Errors", func(t *testing.T) {
+		var calls int32
+		router := newRouter(func(c *gin.Context) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				c.Error(errors.Conflict("try again"))
+				return
+			}
+			c.JSON(http.StatusCreated, gin.H{"id": 1})
+		})
+
+		assert.Equal(t, http.StatusConflict, post(router, "key-1", `{}`).Code)
+		assert.Equal(t, http.StatusCreated, post(router, "key-1", `{}`).Code)
+		assert.Equal(t, int32(2), calls)
+	})
+
+	t.Run("Passes Through Without Key", func(t *testing.T) {
+		var calls int32
+		router := newRouter(func(c *gin.Context) {
+			atomic.AddInt32(&calls, 1)
+			c.JSON(http.StatusCreated, gin.H{"id": 1})
+		})
+
+		post(router, "", `{}`)
+		post(router, "", `{}`)
+		assert.Equal(t, int32(2), calls)
+	})
+
+	t.Run("Rejects Long Key", func(t *testing.T) {
+		router := newRouter(func(c *gin.Context) {
+			c.JSON(http.StatusCreated, gin.H{"id": 1})
+		})
+
+		w := post(router, strings.Repeat("k", maxIdempotencyKeyLength+1), `{}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Rejects Oversized Body", func(t *testing.T) {
+		var calls int32
+		router := newRouter(func(c *gin.Context) {
+			atomic.AddInt32(&calls, 1)
+			c.JSON(http.StatusCreated, gin.H{"id": 1})
+		})
+
+		w := post(router, "key-1", `{"name":"`+strings.Repeat("a", 64)+`"}`)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Contains(t, w.Body.String(), "payload_too_large")
+		assert.Equal(t, int32(0), calls)
+	})
+
+	t.Run("Serializes Concurrent Requests", func(t *testing.T) {
+		var calls int32
+		router := newRouter(func(c *gin.Context) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(100 * time.Millisecond)
+			c.JSON(http.StatusCreated, gin.H{"id": 1})
+		})
+
+		var wg sync.WaitGroup
+		codes := make([]int, 5)
+		for i := range codes {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				codes[i] = post(router, "key-1", `{}`).Code
+			}(i)
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(1), calls)
+		for _, code := range codes {
+			assert.Equal(t, http.StatusCreated, code)
+		}
+	})
+
+	t.Run("Holds Lock Past Lock TTL", func(t *testing.T) {
+		var calls int32
+		router := newRouterWithLockTTL(40*time.Millisecond, func(c *gin.Context) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(200 * time.Millisecond)
+			c.JSON(http.StatusCreated, gin.H{"id": 1})
+		})
+
+		first := make(chan int)
+		go func() {
+			first <- post(router, "key-1", `{}`).Code
+		}()
+		time.Sleep(100 * time.Millisecond)
+		retry := post(router, "key-1", `{}`)
+
+		assert.Equal(t, http.StatusCreated, <-first)
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, "true", retry.Header().Get(IdempotentReplayedHeader))
+		assert.Equal(t, int32(1), calls)
+	})
+}
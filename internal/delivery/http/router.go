@@ -1,42 +1,103 @@
 package http
 
 import (
+	"expvar"
+	"log"
+
 	"github.com/gin-gonic/gin"
+	"github.com/reinhardjs/dot-backend-test/config"
 	"github.com/reinhardjs/dot-backend-test/internal/delivery/http/handler"
+	"github.com/reinhardjs/dot-backend-test/internal/delivery/http/middleware"
+	"github.com/reinhardjs/dot-backend-test/internal/infrastructure/cache"
 	"github.com/reinhardjs/dot-backend-test/internal/usecase"
+	"github.com/reinhardjs/dot-backend-test/pkg/cursor"
 	"github.com/reinhardjs/dot-backend-test/pkg/errors"
 )
 
-func NewRouter(productUsecase usecase.ProductUsecase, categoryUsecase usecase.CategoryUsecase) *gin.Engine {
+func NewRouter(cfg *config.Config, cache cache.Cache, productUsecase usecase.ProductUsecase, categoryUsecase usecase.CategoryUsecase) *gin.Engine {
 	router := gin.Default()
 
 	router.Use(errors.ErrorHandler())
+	router.Use(middleware.Audit(cfg.TrustActorHeader))
+
+	// Exports, imports and batches handle many rows per request, so they get
+	// a limit of their own instead of the one for ordinary requests.
+	timeout := middleware.Timeout(cfg.ServerTimeout)
+	bulkTimeout := middleware.Timeout(cfg.BulkTimeout)
+
+	cursors := newCursorCodec(cfg.CursorSecret)
+	opts := handler.Options{
+		RequireIfMatch: cfg.RequireIfMatch,
+		CacheControl:   cfg.CacheControl,
+	}
 
-	productHandler := handler.NewProductHandler(productUsecase)
-	categoryHandler := handler.NewCategoryHandler(categoryUsecase)
+	productHandler := handler.NewProductHandler(productUsecase, cursors, opts)
+	categoryHandler := handler.NewCategoryHandler(categoryUsecase, cursors, opts)
+	healthHandler := handler.NewHealthHandler(cache)
+	idempotency := middleware.Idempotency(cache, cfg.IdempotencyTTL, cfg.IdempotencyLockTTL, middleware.DefaultMaxBodySize)
+	bulkIdempotency := middleware.Idempotency(cache, cfg.IdempotencyTTL, cfg.IdempotencyLockTTL, handler.MaxImportSize)
 
-	v1 := router.Group("/api/v1")
+	router.GET("/health", timeout, healthHandler.Health)
+	router.GET("/debug/vars", gin.WrapH(expvar.Handler()))
+
+	api := router.Group("/api/v1")
+	{
+		bulk := api.Group("", bulkTimeout)
+		bulk.GET("/products/export", productHandler.ExportProducts)
+		// Custom methods: POST /products:batch and POST /products:import
+		bulk.POST("/products:action", bulkIdempotency, productHandler.ProductAction)
+	}
+	v1 := api.Group("", timeout)
 	{
 		products := v1.Group("/products")
 		{
-			products.POST("", productHandler.CreateProduct)
+			products.POST("", idempotency, productHandler.CreateProduct)
 			products.GET("", productHandler.GetAllProducts)
 			products.GET("/:id", productHandler.GetProduct)
+			products.GET("/:id/history", productHandler.GetProductHistory)
 			products.PUT("/:id", productHandler.UpdateProduct)
-			products.PATCH("/:id", productHandler.UpdateProduct)
+			products.PATCH("/:id", productHandler.PatchProduct)
 			products.DELETE("/:id", productHandler.DeleteProduct)
 		}
 
 		categories := v1.Group("/categories")
 		{
-			categories.POST("", categoryHandler.CreateCategory)
+			categories.POST("", idempotency, categoryHandler.CreateCategory)
 			categories.GET("", categoryHandler.GetAllCategories)
+			categories.GET("/tree", categoryHandler.GetCategoryTree)
 			categories.GET("/:id", categoryHandler.GetCategory)
+			categories.GET("/:id/children", categoryHandler.GetCategoryChildren)
+			categories.GET("/:id/ancestors", categoryHandler.GetCategoryAncestors)
+			categories.GET("/:id/descendants", categoryHandler.GetCategoryDescendants)
+			categories.GET("/:id/history", categoryHandler.GetCategoryHistory)
 			categories.PUT("/:id", categoryHandler.UpdateCategory)
-			categories.PATCH("/:id", categoryHandler.UpdateCategory)
+			categories.PATCH("/:id", categoryHandler.PatchCategory)
 			categories.DELETE("/:id", categoryHandler.DeleteCategory)
 		}
+
+		trash := v1.Group("/trash")
+		{
+			trash.GET("/products", productHandler.GetDeletedProducts)
+			trash.POST("/products/:id/restore", productHandler.RestoreProduct)
+			trash.DELETE("/products/:id", productHandler.PurgeProduct)
+			trash.GET("/categories", categoryHandler.GetDeletedCategories)
+			trash.POST("/categories/:id/restore", categoryHandler.RestoreCategory)
+			trash.DELETE("/categories/:id", categoryHandler.PurgeCategory)
+		}
 	}
 
 	return router
 }
+
+func newCursorCodec(secret string) *cursor.Codec {
+	if secret != "" {
+		return cursor.NewCodec(secret)
+	}
+
+	log.Println("pagination.cursor_secret is not set, using a random secret; cursors will not survive restarts")
+	codec, err := cursor.NewRandomCodec()
+	if err != nil {
+		log.Fatalf("Failed to generate cursor secret: %v", err)
+	}
+	return codec
+}
@@ -0,0 +1,28 @@
+package entity
+
+import "time"
+
+// Entity types and actions of audit entries.
+const (
+	AuditProduct  = "product"
+	AuditCategory = "category"
+
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+)
+
+// AuditEntry records a write to a product or category: who made it, in which
+// request, and the changed fields as a JSON object mapping each field to its
+// value before and after.
+type AuditEntry struct {
+	ID         uint      `gorm:"primaryKey"`
+	EntityType string    `gorm:"size:20;not null;index:idx_audit_entries_entity"`
+	EntityID   uint      `gorm:"not null;index:idx_audit_entries_entity"`
+	Action     string    `gorm:"size:20;not null"`
+	Actor      string    `gorm:"size:100;not null"`
+	RequestID  string    `gorm:"size:100"`
+	Changes    string    `gorm:"type:jsonb;not null"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
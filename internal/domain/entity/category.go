@@ -9,6 +9,8 @@ import (
 type Category struct {
 	ID        uint           `gorm:"primaryKey"`
 	Name      string         `gorm:"size:100;not null"`
+	ParentID  *uint          `gorm:"index"`
+	Version   uint           `gorm:"not null;default:1"`
 	CreatedAt time.Time      `gorm:"autoCreateTime"`
 	UpdatedAt time.Time      `gorm:"autoUpdateTime"`
 	DeletedAt gorm.DeletedAt `gorm:"index"`
@@ -7,12 +7,16 @@ import (
 )
 
 type Product struct {
-	ID         uint           `gorm:"primaryKey"`
-	Name       string         `gorm:"size:100;not null"`
-	Price      float64        `gorm:"not null"`
-	CategoryID uint           `gorm:"not null"`
-	Category   Category       `gorm:"foreignKey:CategoryID"`
-	CreatedAt  time.Time      `gorm:"autoCreateTime"`
-	UpdatedAt  time.Time      `gorm:"autoUpdateTime"`
-	DeletedAt  gorm.DeletedAt `gorm:"index"`
+	ID         uint     `gorm:"primaryKey"`
+	Name       string   `gorm:"size:100;not null"`
+	Price      float64  `gorm:"not null"`
+	CategoryID uint     `gorm:"not null"`
+	Category   Category `gorm:"foreignKey:CategoryID"`
+	// ExternalKey identifies the product in an external catalog, such as a
+	// SKU, and is what imports match existing products on.
+	ExternalKey *string        `gorm:"size:100;uniqueIndex:idx_products_external_key,where:deleted_at IS NULL"`
+	Version     uint           `gorm:"not null;default:1"`
+	CreatedAt   time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt   time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
 }
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/reinhardjs/dot-backend-test/internal/domain/entity"
+	"gorm.io/gorm"
+)
+
+// AuditQuery selects the audit entries of one record. A non-empty Field
+// keeps only the entries that changed that field.
+type AuditQuery struct {
+	EntityType string
+	EntityID   uint
+	Field      string
+	Page       int
+	PageSize   int
+}
+
+type AuditRepository interface {
+	Create(ctx context.Context, entries []entity.AuditEntry) error
+	FindByQuery(ctx context.Context, query AuditQuery) ([]entity.AuditEntry, int64, error)
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+func (r *auditRepository) Create(ctx context.Context, entries []entity.AuditEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return translateError(r.db.WithContext(ctx).CreateInBatches(entries, createBatchSize).Error, "audit entry")
+}
+
+// FindByQuery returns a page of the matching entries, newest first, and their
+// total count.
+func (r *auditRepository) FindByQuery(ctx context.Context, query AuditQuery) ([]entity.AuditEntry, int64, error) {
+	page, pageSize := NormalizePage(query.Page, query.PageSize)
+	db := r.db.WithContext(ctx).Model(&entity.AuditEntry{}).
+		Where("entity_type = ? AND entity_id = ?", query.EntityType, query.EntityID)
+	if query.Field != "" {
+		db = db.Where("changes -> ? IS NOT NULL", query.Field)
+	}
+	db = db.Session(&gorm.Session{})
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, translateError(err, "audit entry")
+	}
+	var entries []entity.AuditEntry
+	err := db.Order("id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&entries).Error
+	return entries, total, translateError(err, "audit entry")
+}
@@ -1,16 +1,65 @@
 package repository
 
 import (
+	"context"
+	"strings"
+	"time"
+
 	"github.com/reinhardjs/dot-backend-test/internal/domain/entity"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CategoryRepository interface {
-	Create(category *entity.Category) error
-	GetByID(id uint) (*entity.Category, error)
-	Update(category *entity.Category) error
-	Delete(id uint) error
-	GetAll() ([]entity.Category, error)
+	Create(ctx context.Context, category *entity.Category) error
+	GetByID(ctx context.Context, id uint) (*entity.Category, error)
+	FindByIDs(ctx context.Context, ids []uint) ([]entity.Category, error)
+	FindByNames(ctx context.Context, names []string) ([]entity.Category, error)
+	Update(ctx context.Context, category *entity.Category, fields ...string) error
+	Delete(ctx context.Context, id uint, version uint) error
+	GetAll(ctx context.Context) ([]entity.Category, error)
+	FindByKeyset(ctx context.Context, sort SortField, after *Keyset, limit int) ([]entity.Category, error)
+	Stats(ctx context.Context) (ListStats, error)
+	Children(ctx context.Context, id uint) ([]entity.Category, error)
+	Ancestors(ctx context.Context, id uint) ([]entity.Category, error)
+	Descendants(ctx context.Context, id uint) ([]entity.Category, error)
+	LockTree(ctx context.Context) error
+	Lock(ctx context.Context, ids ...uint) ([]entity.Category, error)
+	FindDeleted(ctx context.Context, page, pageSize int) ([]entity.Category, int64, error)
+	FindDeletedByID(ctx context.Context, id uint) (*entity.Category, error)
+	CountReferences(ctx context.Context, id uint) (int64, error)
+	Restore(ctx context.Context, id uint) error
+	Purge(ctx context.Context, id uint) error
+	PurgeDeletedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+var CategorySortColumns = map[string]string{
+	"id":         "categories.id",
+	"name":       "categories.name",
+	"created_at": "categories.created_at",
+	"updated_at": "categories.updated_at",
+}
+
+// categoryTreeLock is the key of the advisory lock that serializes changes
+// to the category hierarchy.
+const categoryTreeLock = 0x63617465
+
+// subtreeCTE selects a category and all its descendants with their depth
+// below it. The schema does not rule out cycles, so the path guards against
+// them.
+const subtreeCTE = `WITH RECURSIVE subtree AS (
+	SELECT id, 0 AS depth, ARRAY[id] AS path
+	FROM categories WHERE id = ? AND deleted_at IS NULL
+	UNION ALL
+	SELECT c.id, s.depth + 1, s.path || c.id
+	FROM categories c JOIN subtree s ON c.parent_id = s.id
+	WHERE c.deleted_at IS NULL AND NOT c.id = ANY(s.path)
+) `
+
+// subtreeIDs is a subquery for the IDs of category id and all its descendants.
+func subtreeIDs(db *gorm.DB, id uint) *gorm.DB {
+	return db.Raw(subtreeCTE+"SELECT id FROM subtree", id)
 }
 
 type categoryRepository struct {
@@ -21,26 +70,240 @@ func NewCategoryRepository(db *gorm.DB) CategoryRepository {
 	return &categoryRepository{db: db}
 }
 
-func (r *categoryRepository) Create(category *entity.Category) error {
-	return r.db.Create(category).Error
+func (r *categoryRepository) Create(ctx context.Context, category *entity.Category) error {
+	return translateError(r.db.WithContext(ctx).Create(category).Error, "category")
 }
 
-func (r *categoryRepository) GetByID(id uint) (*entity.Category, error) {
+func (r *categoryRepository) GetByID(ctx context.Context, id uint) (*entity.Category, error) {
 	var category entity.Category
-	err := r.db.First(&category, id).Error
-	return &category, err
+	err := r.db.WithContext(ctx).First(&category, id).Error
+	if err != nil {
+		return nil, translateError(err, "category")
+	}
+	return &category, nil
+}
+
+// FindByIDs returns the categories with the given IDs that exist, in no
+// particular order.
+func (r *categoryRepository) FindByIDs(ctx context.Context, ids []uint) ([]entity.Category, error) {
+	var categories []entity.Category
+	if len(ids) == 0 {
+		return categories, nil
+	}
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&categories).Error
+	return categories, translateError(err, "category")
+}
+
+// FindByNames returns the categories whose name matches one of names, ignoring
+// case. Names are not unique, so a name may match several categories.
+func (r *categoryRepository) FindByNames(ctx context.Context, names []string) ([]entity.Category, error) {
+	var categories []entity.Category
+	if len(names) == 0 {
+		return categories, nil
+	}
+	lower := make([]string, len(names))
+	for i, name := range names {
+		lower[i] = strings.ToLower(name)
+	}
+	err := r.db.WithContext(ctx).Where("LOWER(name) IN ?", lower).Find(&categories).Error
+	return categories, translateError(err, "category")
+}
+
+// Update writes only the given fields of category (plus UpdatedAt), so fields
+// that were not changed are never overwritten. The row is only updated if it
+// still has category.Version, which is then incremented.
+func (r *categoryRepository) Update(ctx context.Context, category *entity.Category, fields ...string) error {
+	version := category.Version
+	category.Version++
+	result := r.db.WithContext(ctx).Model(category).
+		Where("version = ?", version).
+		Select(append([]string{"Version"}, fields...)).
+		Updates(category)
+	if result.Error != nil {
+		category.Version = version
+		return translateError(result.Error, "category")
+	}
+	if result.RowsAffected == 0 {
+		category.Version = version
+		return versionConflict(r.db.WithContext(ctx), &entity.Category{}, category.ID, "category")
+	}
+	return nil
+}
+
+// Delete soft deletes the category. A non-zero version makes the delete
+// conditional on the category still having that version.
+func (r *categoryRepository) Delete(ctx context.Context, id uint, version uint) error {
+	db := r.db.WithContext(ctx)
+	if version > 0 {
+		db = db.Where("version = ?", version)
+	}
+	result := db.Delete(&entity.Category{}, id)
+	if result.Error != nil {
+		return translateError(result.Error, "category")
+	}
+	if result.RowsAffected == 0 {
+		return versionConflict(r.db.WithContext(ctx), &entity.Category{}, id, "category")
+	}
+	return nil
+}
+
+func (r *categoryRepository) GetAll(ctx context.Context) ([]entity.Category, error) {
+	var categories []entity.Category
+	err := r.db.WithContext(ctx).Find(&categories).Error
+	return categories, translateError(err, "category")
+}
+
+func (r *categoryRepository) FindByKeyset(ctx context.Context, sort SortField, after *Keyset, limit int) ([]entity.Category, error) {
+	var categories []entity.Category
+	err := applyKeyset(r.db.WithContext(ctx), sort, after, CategorySortColumns).
+		Limit(limit).
+		Find(&categories).Error
+	return categories, translateError(err, "category")
+}
+
+func (r *categoryRepository) Stats(ctx context.Context) (ListStats, error) {
+	stats, err := scanListStats(r.db.WithContext(ctx).Model(&entity.Category{}), "categories")
+	return stats, translateError(err, "category")
 }
 
-func (r *categoryRepository) Update(category *entity.Category) error {
-	return r.db.Save(category).Error
+// Children returns the direct subcategories of category id, by name.
+func (r *categoryRepository) Children(ctx context.Context, id uint) ([]entity.Category, error) {
+	var categories []entity.Category
+	err := r.db.WithContext(ctx).Where("parent_id = ?", id).Order("name, id").Find(&categories).Error
+	return categories, translateError(err, "category")
 }
 
-func (r *categoryRepository) Delete(id uint) error {
-	return r.db.Delete(&entity.Category{}, id).Error
+// Ancestors returns the parent of category id, its parent and so on, starting
+// at the root.
+func (r *categoryRepository) Ancestors(ctx context.Context, id uint) ([]entity.Category, error) {
+	var categories []entity.Category
+	err := r.db.WithContext(ctx).Raw(`WITH RECURSIVE chain AS (
+	SELECT id, parent_id, 0 AS depth, ARRAY[id] AS path
+	FROM categories WHERE id = ? AND deleted_at IS NULL
+	UNION ALL
+	SELECT c.id, c.parent_id, chain.depth + 1, chain.path || c.id
+	FROM categories c JOIN chain ON c.id = chain.parent_id
+	WHERE c.deleted_at IS NULL AND NOT c.id = ANY(chain.path)
+)
+SELECT categories.* FROM categories JOIN chain ON chain.id = categories.id
+WHERE chain.depth > 0
+ORDER BY chain.depth DESC`, id).Scan(&categories).Error
+	return categories, translateError(err, "category")
 }
 
-func (r *categoryRepository) GetAll() ([]entity.Category, error) {
+// Descendants returns all subcategories below category id, level by level.
+func (r *categoryRepository) Descendants(ctx context.Context, id uint) ([]entity.Category, error) {
 	var categories []entity.Category
-	err := r.db.Find(&categories).Error
-	return categories, err
+	err := r.db.WithContext(ctx).Raw(subtreeCTE+`SELECT categories.* FROM categories JOIN subtree ON subtree.id = categories.id
+WHERE subtree.depth > 0
+ORDER BY subtree.depth, categories.name, categories.id`, id).Scan(&categories).Error
+	return categories, translateError(err, "category")
+}
+
+// LockTree blocks other changes to the category hierarchy until the current
+// transaction ends, so that two concurrent moves cannot create a cycle.
+func (r *categoryRepository) LockTree(ctx context.Context) error {
+	err := r.db.WithContext(ctx).Exec("SELECT pg_advisory_xact_lock(?)", categoryTreeLock).Error
+	return translateError(err, "category")
+}
+
+// Lock returns the categories with the given IDs that exist and keeps them
+// locked until the current transaction ends. While locked, no products can be
+// added to them. Rows are locked in ID order to avoid deadlocks.
+func (r *categoryRepository) Lock(ctx context.Context, ids ...uint) ([]entity.Category, error) {
+	var categories []entity.Category
+	err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", ids).
+		Order("id").
+		Find(&categories).Error
+	return categories, translateError(err, "category")
+}
+
+// FindDeleted returns a page of soft deleted categories, most recently
+// deleted first, and their total count.
+func (r *categoryRepository) FindDeleted(ctx context.Context, page, pageSize int) ([]entity.Category, int64, error) {
+	page, pageSize = NormalizePage(page, pageSize)
+	db := r.db.WithContext(ctx).Unscoped().Model(&entity.Category{}).Where("deleted_at IS NOT NULL").Session(&gorm.Session{})
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, translateError(err, "category")
+	}
+	var categories []entity.Category
+	err := db.Order("deleted_at DESC, id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&categories).Error
+	return categories, total, translateError(err, "category")
+}
+
+func (r *categoryRepository) FindDeletedByID(ctx context.Context, id uint) (*entity.Category, error) {
+	var category entity.Category
+	err := r.db.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL").First(&category, id).Error
+	if err != nil {
+		return nil, translateError(err, "deleted category")
+	}
+	return &category, nil
+}
+
+// CountReferences counts the products and subcategories, deleted or not,
+// that still refer to category id.
+func (r *categoryRepository) CountReferences(ctx context.Context, id uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Raw(`SELECT
+	(SELECT COUNT(*) FROM products WHERE category_id = ?) +
+	(SELECT COUNT(*) FROM categories WHERE parent_id = ?)`, id, id).Scan(&count).Error
+	return count, translateError(err, "category")
+}
+
+// Restore undeletes a soft deleted category and increments its version.
+func (r *categoryRepository) Restore(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Unscoped().Model(&entity.Category{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Updates(map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")})
+	if result.Error != nil {
+		return translateError(result.Error, "category")
+	}
+	if result.RowsAffected == 0 {
+		return errors.NotFound("deleted category not found")
+	}
+	return nil
+}
+
+// Purge permanently deletes a soft deleted category.
+func (r *categoryRepository) Purge(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL").Delete(&entity.Category{}, id)
+	if result.Error != nil {
+		return translateError(result.Error, "category")
+	}
+	if result.RowsAffected == 0 {
+		return errors.NotFound("deleted category not found")
+	}
+	return nil
+}
+
+// PurgeDeletedBefore permanently deletes the categories that were soft
+// deleted before the given time and that no product or subcategory refers to
+// anymore. It returns how many were deleted.
+func (r *categoryRepository) PurgeDeletedBefore(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Unscoped().
+		Where("deleted_at < ?", before).
+		Where("NOT EXISTS (SELECT 1 FROM products WHERE products.category_id = categories.id)").
+		Where("NOT EXISTS (SELECT 1 FROM categories child WHERE child.parent_id = categories.id)").
+		Delete(&entity.Category{})
+	return result.RowsAffected, translateError(result.Error, "category")
+}
+
+func CategoryKeyset(category entity.Category, field string) Keyset {
+	keyset := Keyset{ID: category.ID}
+	switch field {
+	case "name":
+		keyset.Value = category.Name
+	case "created_at":
+		keyset.Value = category.CreatedAt
+	case "updated_at":
+		keyset.Value = category.UpdatedAt
+	}
+	return keyset
 }
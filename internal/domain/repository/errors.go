@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"net"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// PostgreSQL SQLSTATE codes for integrity constraint violations.
+const (
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505"
+	checkViolation      = "23514"
+)
+
+// translateError maps GORM and PostgreSQL errors to domain errors, so that
+// driver messages never reach the delivery layer.
+func translateError(err error, entity string) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.NotFound("%s not found", entity)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case foreignKeyViolation:
+			return errors.Wrap(errors.KindConflict, err, "%s references a record that does not exist or is still referenced", entity).WithCode("reference_violation")
+		case uniqueViolation:
+			return errors.Wrap(errors.KindConflict, err, "%s already exists", entity).WithCode("already_exists")
+		case checkViolation:
+			return errors.Wrap(errors.KindValidation, err, "%s violates a check constraint", entity).WithCode("constraint_violation")
+		}
+	}
+
+	if isUnavailable(err) {
+		return errors.Unavailable(err, "database is unavailable")
+	}
+	return errors.Internal(err)
+}
+
+// versionConflict explains why a conditional write to the record with the
+// given ID matched no rows: either it does not exist or its version changed.
+func versionConflict(db *gorm.DB, model interface{}, id uint, entity string) error {
+	if err := db.Select("id").First(model, id).Error; err != nil {
+		return translateError(err, entity)
+	}
+	return errors.PreconditionFailed("%s has been modified by another request", entity)
+}
+
+func isUnavailable(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) || errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) || pgconn.Timeout(err) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -1,16 +1,62 @@
 package repository
 
 import (
+	"context"
+	"time"
+
 	"github.com/reinhardjs/dot-backend-test/internal/domain/entity"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
 	"gorm.io/gorm"
 )
 
+// createBatchSize is the number of products inserted per statement by
+// CreateBatch.
+const createBatchSize = 500
+
+var ProductSortColumns = map[string]string{
+	"id":         "products.id",
+	"name":       "products.name",
+	"price":      "products.price",
+	"created_at": "products.created_at",
+	"updated_at": "products.updated_at",
+}
+
+type ProductQuery struct {
+	Page                 int
+	PageSize             int
+	CategoryID           *uint
+	IncludeSubcategories bool
+	MinPrice             *float64
+	MaxPrice             *float64
+	Name                 string
+	CreatedFrom          *time.Time
+	CreatedTo            *time.Time
+	UpdatedFrom          *time.Time
+	UpdatedTo            *time.Time
+	Sort                 []SortField
+}
+
 type ProductRepository interface {
-	Create(product *entity.Product) error
-	FindByID(id uint) (*entity.Product, error)
-	Update(product *entity.Product) error
-	Delete(id uint) error
-	FindAll() ([]entity.Product, error)
+	Create(ctx context.Context, product *entity.Product) error
+	CreateBatch(ctx context.Context, products []entity.Product) error
+	FindByID(ctx context.Context, id uint) (*entity.Product, error)
+	FindByIDs(ctx context.Context, ids []uint) ([]entity.Product, error)
+	FindByExternalKeys(ctx context.Context, keys []string) ([]entity.Product, error)
+	Update(ctx context.Context, product *entity.Product, fields ...string) error
+	Delete(ctx context.Context, id uint, version uint) error
+	CountByCategory(ctx context.Context, categoryID uint) (int64, error)
+	FindByCategory(ctx context.Context, categoryID uint) ([]entity.Product, error)
+	ReassignCategory(ctx context.Context, from, to uint) (int64, error)
+	DeleteByCategory(ctx context.Context, categoryID uint) (int64, error)
+	FindByQuery(ctx context.Context, query ProductQuery) ([]entity.Product, int64, error)
+	FindByKeyset(ctx context.Context, query ProductQuery, after *Keyset) ([]entity.Product, error)
+	Stats(ctx context.Context, query ProductQuery) (ListStats, error)
+	Each(ctx context.Context, query ProductQuery, fn func(product entity.Product) error) error
+	FindDeleted(ctx context.Context, page, pageSize int) ([]entity.Product, int64, error)
+	FindDeletedByID(ctx context.Context, id uint) (*entity.Product, error)
+	Restore(ctx context.Context, id uint) error
+	Purge(ctx context.Context, id uint) error
+	PurgeDeletedBefore(ctx context.Context, before time.Time) (int64, error)
 }
 
 type productRepository struct {
@@ -21,26 +67,288 @@ func NewProductRepository(db *gorm.DB) ProductRepository {
 	return &productRepository{db: db}
 }
 
-func (r *productRepository) Create(product *entity.Product) error {
-	return r.db.Create(product).Error
+func (r *productRepository) Create(ctx context.Context, product *entity.Product) error {
+	return translateError(r.db.WithContext(ctx).Create(product).Error, "product")
 }
 
-func (r *productRepository) FindByID(id uint) (*entity.Product, error) {
+// CreateBatch inserts products with multi-row inserts and fills in their IDs.
+func (r *productRepository) CreateBatch(ctx context.Context, products []entity.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+	return translateError(r.db.WithContext(ctx).CreateInBatches(products, createBatchSize).Error, "product")
+}
+
+func (r *productRepository) FindByID(ctx context.Context, id uint) (*entity.Product, error) {
 	var product entity.Product
-	err := r.db.Preload("Category").First(&product, id).Error
-	return &product, err
+	err := r.db.WithContext(ctx).Preload("Category").First(&product, id).Error
+	if err != nil {
+		return nil, translateError(err, "product")
+	}
+	return &product, nil
+}
+
+// FindByIDs returns the products with the given IDs that exist, in no
+// particular order.
+func (r *productRepository) FindByIDs(ctx context.Context, ids []uint) ([]entity.Product, error) {
+	var products []entity.Product
+	if len(ids) == 0 {
+		return products, nil
+	}
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&products).Error
+	return products, translateError(err, "product")
+}
+
+// FindByExternalKeys returns the products with the given external keys that
+// exist, in no particular order.
+func (r *productRepository) FindByExternalKeys(ctx context.Context, keys []string) ([]entity.Product, error) {
+	var products []entity.Product
+	if len(keys) == 0 {
+		return products, nil
+	}
+	err := r.db.WithContext(ctx).Where("external_key IN ?", keys).Find(&products).Error
+	return products, translateError(err, "product")
+}
+
+// Update writes only the given fields of product (plus UpdatedAt), so fields
+// that were not changed are never overwritten. The row is only updated if it
+// still has product.Version, which is then incremented.
+func (r *productRepository) Update(ctx context.Context, product *entity.Product, fields ...string) error {
+	version := product.Version
+	product.Version++
+	result := r.db.WithContext(ctx).Model(product).
+		Where("version = ?", version).
+		Select(append([]string{"Version"}, fields...)).
+		Updates(product)
+	if result.Error != nil {
+		product.Version = version
+		return translateError(result.Error, "product")
+	}
+	if result.RowsAffected == 0 {
+		product.Version = version
+		return versionConflict(r.db.WithContext(ctx), &entity.Product{}, product.ID, "product")
+	}
+	return nil
 }
 
-func (r *productRepository) Update(product *entity.Product) error {
-	return r.db.Save(product).Error
+// Delete soft deletes the product. A non-zero version makes the delete
+// conditional on the product still having that version.
+func (r *productRepository) Delete(ctx context.Context, id uint, version uint) error {
+	db := r.db.WithContext(ctx)
+	if version > 0 {
+		db = db.Where("version = ?", version)
+	}
+	result := db.Delete(&entity.Product{}, id)
+	if result.Error != nil {
+		return translateError(result.Error, "product")
+	}
+	if result.RowsAffected == 0 {
+		return versionConflict(r.db.WithContext(ctx), &entity.Product{}, id, "product")
+	}
+	return nil
 }
 
-func (r *productRepository) Delete(id uint) error {
-	return r.db.Delete(&entity.Product{}, id).Error
+func (r *productRepository) CountByCategory(ctx context.Context, categoryID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&entity.Product{}).Where("category_id = ?", categoryID).Count(&count).Error
+	return count, translateError(err, "product")
 }
 
-func (r *productRepository) FindAll() ([]entity.Product, error) {
+func (r *productRepository) FindByCategory(ctx context.Context, categoryID uint) ([]entity.Product, error) {
 	var products []entity.Product
-	err := r.db.Preload("Category").Find(&products).Error
-	return products, err
+	err := r.db.WithContext(ctx).Where("category_id = ?", categoryID).Find(&products).Error
+	return products, translateError(err, "product")
+}
+
+// ReassignCategory moves every product of category from to category to and
+// returns how many were moved. Each moved product gets a new version.
+func (r *productRepository) ReassignCategory(ctx context.Context, from, to uint) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&entity.Product{}).
+		Where("category_id = ?", from).
+		Updates(map[string]interface{}{"category_id": to, "version": gorm.Expr("version + 1")})
+	return result.RowsAffected, translateError(result.Error, "product")
+}
+
+// DeleteByCategory soft deletes every product of the category and returns
+// how many were deleted.
+func (r *productRepository) DeleteByCategory(ctx context.Context, categoryID uint) (int64, error) {
+	result := r.db.WithContext(ctx).Where("category_id = ?", categoryID).Delete(&entity.Product{})
+	return result.RowsAffected, translateError(result.Error, "product")
+}
+
+func (r *productRepository) FindByQuery(ctx context.Context, query ProductQuery) ([]entity.Product, int64, error) {
+	page, pageSize := NormalizePage(query.Page, query.PageSize)
+
+	var total int64
+	if err := r.applyFilters(r.db.WithContext(ctx).Model(&entity.Product{}), query).Count(&total).Error; err != nil {
+		return nil, 0, translateError(err, "product")
+	}
+
+	var products []entity.Product
+	err := r.applyFilters(r.db.WithContext(ctx).Preload("Category"), query).
+		Order(orderClause(query.Sort, ProductSortColumns)).
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&products).Error
+	return products, total, translateError(err, "product")
+}
+
+// FindByKeyset returns up to query.PageSize products ordered by the first
+// field of query.Sort (or the primary key) that come after the given position.
+func (r *productRepository) FindByKeyset(ctx context.Context, query ProductQuery, after *Keyset) ([]entity.Product, error) {
+	var sort SortField
+	if len(query.Sort) > 0 {
+		sort = query.Sort[0]
+	}
+
+	var products []entity.Product
+	db := r.applyFilters(r.db.WithContext(ctx).Preload("Category"), query)
+	err := applyKeyset(db, sort, after, ProductSortColumns).
+		Limit(query.PageSize).
+		Find(&products).Error
+	return products, translateError(err, "product")
+}
+
+// Stats summarizes the products matching the filters of query, ignoring its
+// paging and ordering.
+func (r *productRepository) Stats(ctx context.Context, query ProductQuery) (ListStats, error) {
+	db := r.applyFilters(r.db.WithContext(ctx).Model(&entity.Product{}), query)
+	stats, err := scanListStats(db, "products")
+	return stats, translateError(err, "product")
+}
+
+// Each calls fn for every product matching the filters of query, in the order
+// of query.Sort, reading them from a database cursor one at a time instead of
+// loading them all. Only the category's ID and name are loaded. It stops at
+// the first error returned by fn.
+func (r *productRepository) Each(ctx context.Context, query ProductQuery, fn func(product entity.Product) error) error {
+	rows, err := r.applyFilters(r.db.WithContext(ctx).Model(&entity.Product{}), query).
+		Select("products.id, products.external_key, products.name, products.price, products.category_id, " +
+			"COALESCE(categories.name, ''), products.version, products.created_at, products.updated_at").
+		Joins("LEFT JOIN categories ON categories.id = products.category_id AND categories.deleted_at IS NULL").
+		Order(orderClause(query.Sort, ProductSortColumns)).
+		Rows()
+	if err != nil {
+		return translateError(err, "product")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var product entity.Product
+		err := rows.Scan(&product.ID, &product.ExternalKey, &product.Name, &product.Price, &product.CategoryID,
+			&product.Category.Name, &product.Version, &product.CreatedAt, &product.UpdatedAt)
+		if err != nil {
+			return translateError(err, "product")
+		}
+		product.Category.ID = product.CategoryID
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+	return translateError(rows.Err(), "product")
+}
+
+func ProductKeyset(product entity.Product, field string) Keyset {
+	keyset := Keyset{ID: product.ID}
+	switch field {
+	case "name":
+		keyset.Value = product.Name
+	case "price":
+		keyset.Value = product.Price
+	case "created_at":
+		keyset.Value = product.CreatedAt
+	case "updated_at":
+		keyset.Value = product.UpdatedAt
+	}
+	return keyset
+}
+
+// FindDeleted returns a page of soft deleted products, most recently deleted
+// first, and their total count.
+func (r *productRepository) FindDeleted(ctx context.Context, page, pageSize int) ([]entity.Product, int64, error) {
+	page, pageSize = NormalizePage(page, pageSize)
+	db := r.db.WithContext(ctx).Unscoped().Model(&entity.Product{}).Where("deleted_at IS NOT NULL").Session(&gorm.Session{})
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, translateError(err, "product")
+	}
+	var products []entity.Product
+	err := db.Order("deleted_at DESC, id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&products).Error
+	return products, total, translateError(err, "product")
+}
+
+func (r *productRepository) FindDeletedByID(ctx context.Context, id uint) (*entity.Product, error) {
+	var product entity.Product
+	err := r.db.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL").First(&product, id).Error
+	if err != nil {
+		return nil, translateError(err, "deleted product")
+	}
+	return &product, nil
+}
+
+// Restore undeletes a soft deleted product and increments its version.
+func (r *productRepository) Restore(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Unscoped().Model(&entity.Product{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Updates(map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")})
+	if result.Error != nil {
+		return translateError(result.Error, "product")
+	}
+	if result.RowsAffected == 0 {
+		return errors.NotFound("deleted product not found")
+	}
+	return nil
+}
+
+// Purge permanently deletes a soft deleted product.
+func (r *productRepository) Purge(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL").Delete(&entity.Product{}, id)
+	if result.Error != nil {
+		return translateError(result.Error, "product")
+	}
+	if result.RowsAffected == 0 {
+		return errors.NotFound("deleted product not found")
+	}
+	return nil
+}
+
+// PurgeDeletedBefore permanently deletes the products that were soft deleted
+// before the given time and returns how many were deleted.
+func (r *productRepository) PurgeDeletedBefore(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Unscoped().Where("deleted_at < ?", before).Delete(&entity.Product{})
+	return result.RowsAffected, translateError(result.Error, "product")
+}
+
+func (r *productRepository) applyFilters(db *gorm.DB, query ProductQuery) *gorm.DB {
+	if query.CategoryID != nil && query.IncludeSubcategories {
+		db = db.Where("products.category_id IN (?)", subtreeIDs(r.db, *query.CategoryID))
+	} else if query.CategoryID != nil {
+		db = db.Where("products.category_id = ?", *query.CategoryID)
+	}
+	if query.MinPrice != nil {
+		db = db.Where("products.price >= ?", *query.MinPrice)
+	}
+	if query.MaxPrice != nil {
+		db = db.Where("products.price <= ?", *query.MaxPrice)
+	}
+	if query.Name != "" {
+		db = db.Where("products.name ILIKE ?", "%"+escapeLike(query.Name)+"%")
+	}
+	if query.CreatedFrom != nil {
+		db = db.Where("products.created_at >= ?", *query.CreatedFrom)
+	}
+	if query.CreatedTo != nil {
+		db = db.Where("products.created_at <= ?", *query.CreatedTo)
+	}
+	if query.UpdatedFrom != nil {
+		db = db.Where("products.updated_at >= ?", *query.UpdatedFrom)
+	}
+	if query.UpdatedTo != nil {
+		db = db.Where("products.updated_at <= ?", *query.UpdatedTo)
+	}
+	return db
 }
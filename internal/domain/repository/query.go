@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// Keyset is the position after which a keyset paginated listing continues:
+// the value of the sort column plus the primary key as a tiebreaker.
+type Keyset struct {
+	Value interface{}
+	ID    uint
+}
+
+// ListStats summarizes a listing for conditional requests: the number of
+// rows in it and the last time any of its rows, including deleted ones,
+// changed.
+type ListStats struct {
+	Count        int64
+	LastModified time.Time
+}
+
+// scanListStats reads the row count and last modification time of the rows
+// of table matched by db. Soft deleted rows only count towards the
+// modification time, so deleting a row also changes it.
+func scanListStats(db *gorm.DB, table string) (ListStats, error) {
+	var row struct {
+		Count        int64
+		LastModified *time.Time
+	}
+	err := db.Unscoped().
+		Select(fmt.Sprintf("COUNT(*) FILTER (WHERE %[1]s.deleted_at IS NULL) AS count, "+
+			"MAX(GREATEST(%[1]s.updated_at, %[1]s.deleted_at)) AS last_modified", table)).
+		Scan(&row).Error
+
+	stats := ListStats{Count: row.Count}
+	if row.LastModified != nil {
+		stats.LastModified = *row.LastModified
+	}
+	return stats, err
+}
+
+// ParseKeysetValue decodes a JSON encoded sort key back into the Go type of
+// the column it was taken from.
+func ParseKeysetValue(field string, raw json.RawMessage) (interface{}, error) {
+	var err error
+	switch field {
+	case "id":
+		return nil, nil
+	case "name":
+		var v string
+		err = json.Unmarshal(raw, &v)
+		return v, err
+	case "price":
+		var v float64
+		err = json.Unmarshal(raw, &v)
+		return v, err
+	case "created_at", "updated_at":
+		var v time.Time
+		err = json.Unmarshal(raw, &v)
+		return v, err
+	}
+	return nil, fmt.Errorf("unsupported keyset field %q", field)
+}
+
+// ParseSort parses a comma separated sort expression such as "price,-created_at".
+// A leading "-" sorts the field in descending order. Only fields present in
+// allowed are accepted.
+func ParseSort(raw string, allowed map[string]string) ([]SortField, error) {
+	var fields []SortField
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		field := SortField{Field: part}
+		if strings.HasPrefix(part, "-") {
+			field = SortField{Field: strings.TrimPrefix(part, "-"), Desc: true}
+		} else if strings.HasPrefix(part, "+") {
+			field.Field = strings.TrimPrefix(part, "+")
+		}
+
+		if _, ok := allowed[field.Field]; !ok {
+			return nil, errors.InvalidField("sort", "unsupported sort field %q", field.Field)
+		}
+		if seen[field.Field] {
+			return nil, errors.InvalidField("sort", "duplicate sort field %q", field.Field)
+		}
+		seen[field.Field] = true
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// NormalizePage clamps page and pageSize to sane values.
+func NormalizePage(page, pageSize int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = DefaultPageSize
+	}
+	if pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
+	return page, pageSize
+}
+
+func orderClause(sort []SortField, columns map[string]string) string {
+	clauses := make([]string, 0, len(sort)+1)
+	hasID := false
+	for _, s := range sort {
+		column := columns[s.Field]
+		if s.Field == "id" {
+			hasID = true
+		}
+		if s.Desc {
+			clauses = append(clauses, column+" DESC")
+		} else {
+			clauses = append(clauses, column+" ASC")
+		}
+	}
+	// Always break ties on the primary key so paging is deterministic.
+	if !hasID {
+		clauses = append(clauses, columns["id"]+" ASC")
+	}
+	return strings.Join(clauses, ", ")
+}
+
+// applyKeyset orders db by sort and the primary key and, when after is set,
+// restricts it to rows strictly past that position using a row value
+// comparison.
+func applyKeyset(db *gorm.DB, sort SortField, after *Keyset, columns map[string]string) *gorm.DB {
+	idColumn := columns["id"]
+	direction, operator := "ASC", ">"
+	if sort.Desc {
+		direction, operator = "DESC", "<"
+	}
+
+	if sort.Field == "" || sort.Field == "id" {
+		if after != nil {
+			db = db.Where(fmt.Sprintf("%s %s ?", idColumn, operator), after.ID)
+		}
+		return db.Order(idColumn + " " + direction)
+	}
+
+	column := columns[sort.Field]
+	if after != nil {
+		db = db.Where(fmt.Sprintf("(%s, %s) %s (?, ?)", column, idColumn, operator), after.Value, after.ID)
+	}
+	return db.Order(column + " " + direction).Order(idColumn + " " + direction)
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// UnitOfWork hands out repositories that share one database handle. Inside
+// Do they are bound to a single transaction, so several repository calls
+// commit or roll back together.
+type UnitOfWork interface {
+	Products() ProductRepository
+	Categories() CategoryRepository
+	Audit() AuditRepository
+
+	// Do runs fn in a transaction and commits it if fn returns nil. Calling
+	// Do on a transactional unit of work nests the work in a savepoint.
+	Do(ctx context.Context, fn func(tx UnitOfWork) error) error
+
+	// AfterCommit registers fn to run once the outermost transaction has
+	// committed; it is dropped on rollback. Outside a transaction fn runs
+	// immediately.
+	AfterCommit(fn func())
+}
+
+type unitOfWork struct {
+	db    *gorm.DB
+	hooks *[]func()
+}
+
+func NewUnitOfWork(db *gorm.DB) UnitOfWork {
+	return &unitOfWork{db: db}
+}
+
+func (u *unitOfWork) Products() ProductRepository {
+	return NewProductRepository(u.db)
+}
+
+func (u *unitOfWork) Categories() CategoryRepository {
+	return NewCategoryRepository(u.db)
+}
+
+func (u *unitOfWork) Audit() AuditRepository {
+	return NewAuditRepository(u.db)
+}
+
+func (u *unitOfWork) Do(ctx context.Context, fn func(tx UnitOfWork) error) error {
+	if u.hooks != nil {
+		// Hooks registered in the savepoint only reach the parent if it is
+		// released; a rolled back savepoint drops them with its work.
+		var hooks []func()
+		err := u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return fn(&unitOfWork{db: tx, hooks: &hooks})
+		})
+		if err != nil {
+			return err
+		}
+		*u.hooks = append(*u.hooks, hooks...)
+		return nil
+	}
+
+	var hooks []func()
+	err := u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&unitOfWork{db: tx, hooks: &hooks})
+	})
+	if err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		hook()
+	}
+	return nil
+}
+
+func (u *unitOfWork) AfterCommit(fn func()) {
+	if u.hooks == nil {
+		fn()
+		return
+	}
+	*u.hooks = append(*u.hooks, fn)
+}
@@ -0,0 +1,263 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned instead of calling the wrapped cache while the
+// circuit breaker is open.
+var ErrCircuitOpen = errors.New("cache circuit breaker is open")
+
+const (
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+)
+
+type BreakerState int
+
+const (
+	StateClosed BreakerState = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// breakerTransitions counts state changes, keyed "from->to", and is served
+// on /debug/vars.
+var breakerTransitions = expvar.NewMap("cache_breaker_transitions")
+
+// CircuitBreaker wraps a Cache so that an unavailable backend costs nothing
+// once it is known to be down. After threshold consecutive failures the
+// circuit opens and every call fails fast with ErrCircuitOpen. Once cooldown
+// has elapsed a single probe call is let through (half-open); its outcome
+// closes the circuit again or reopens it for another cooldown.
+//
+// Invalidations attempted while the circuit is open are lost, so entries
+// written before an outage may be served until their TTL runs out.
+type CircuitBreaker struct {
+	cache     Cache
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+	now      func() time.Time
+}
+
+func NewCircuitBreaker(cache Cache, threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = DefaultBreakerThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultBreakerCooldown
+	}
+	return &CircuitBreaker{
+		cache:     cache,
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Trip opens the circuit immediately, e.g. when the backend is already
+// unreachable at startup.
+func (b *CircuitBreaker) Trip() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.open()
+}
+
+func (b *CircuitBreaker) Get(ctx context.Context, key string) ([]byte, error) {
+	if !b.allow() {
+		return nil, ErrCircuitOpen
+	}
+	value, err := b.cache.Get(ctx, key)
+	b.record(err)
+	return value, err
+}
+
+func (b *CircuitBreaker) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	if !b.allow() {
+		return ErrCircuitOpen
+	}
+	err := b.cache.Set(ctx, key, value, ttl, tags...)
+	b.record(err)
+	return err
+}
+
+func (b *CircuitBreaker) Replace(ctx context.Context, key string, old, value []byte, ttl time.Duration, tags ...string) (bool, error) {
+	if !b.allow() {
+		return false, ErrCircuitOpen
+	}
+	replaced, err := b.cache.Replace(ctx, key, old, value, ttl, tags...)
+	b.record(err)
+	return replaced, err
+}
+
+func (b *CircuitBreaker) Delete(ctx context.Context, keys ...string) error {
+	if !b.allow() {
+		return ErrCircuitOpen
+	}
+	err := b.cache.Delete(ctx, keys...)
+	b.record(err)
+	return err
+}
+
+func (b *CircuitBreaker) DeleteByTag(ctx context.Context, tags ...string) error {
+	if !b.allow() {
+		return ErrCircuitOpen
+	}
+	err := b.cache.DeleteByTag(ctx, tags...)
+	b.record(err)
+	return err
+}
+
+func (b *CircuitBreaker) TTL(ctx context.Context, key string) (time.Duration, error) {
+	if !b.allow() {
+		return 0, ErrCircuitOpen
+	}
+	ttl, err := b.cache.TTL(ctx, key)
+	b.record(err)
+	return ttl, err
+}
+
+func (b *CircuitBreaker) Incr(ctx context.Context, key string) (int64, error) {
+	if !b.allow() {
+		return 0, ErrCircuitOpen
+	}
+	value, err := b.cache.Incr(ctx, key)
+	b.record(err)
+	return value, err
+}
+
+// Lock passes through to the wrapped cache if it is a Locker. Without a lock
+// backend, or while the circuit is open, the lock is reported as acquired so
+// that callers simply go on loading.
+func (b *CircuitBreaker) Lock(ctx context.Context, key string, ttl time.Duration) (string, bool, error) {
+	locker, ok := b.cache.(Locker)
+	if !ok || !b.allow() {
+		return "", true, nil
+	}
+	token, acquired, err := locker.Lock(ctx, key, ttl)
+	b.record(err)
+	return token, acquired, err
+}
+
+func (b *CircuitBreaker) Extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	locker, ok := b.cache.(Locker)
+	if !ok || token == "" || !b.allow() {
+		return true, nil
+	}
+	extended, err := locker.Extend(ctx, key, token, ttl)
+	b.record(err)
+	return extended, err
+}
+
+func (b *CircuitBreaker) Unlock(ctx context.Context, key, token string) error {
+	locker, ok := b.cache.(Locker)
+	if !ok || token == "" || !b.allow() {
+		return nil
+	}
+	err := locker.Unlock(ctx, key, token)
+	b.record(err)
+	return err
+}
+
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.transition(StateHalfOpen)
+		b.probing = true
+		return true
+	case StateHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// A request abandoned by the client says nothing about the health of the
+	// backend, so it neither counts as a failure nor as a success. A canceled
+	// probe only frees the slot for the next one.
+	if errors.Is(err, context.Canceled) {
+		if b.state == StateHalfOpen {
+			b.probing = false
+		}
+		return
+	}
+	failed := err != nil && !errors.Is(err, ErrMiss)
+
+	if b.state == StateHalfOpen {
+		b.probing = false
+		if failed {
+			b.open()
+		} else {
+			b.failures = 0
+			b.transition(StateClosed)
+		}
+		return
+	}
+
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == StateClosed && b.failures >= b.threshold {
+		b.open()
+	}
+}
+
+func (b *CircuitBreaker) open() {
+	b.openedAt = b.now()
+	b.probing = false
+	b.transition(StateOpen)
+}
+
+func (b *CircuitBreaker) transition(to BreakerState) {
+	from := b.state
+	if from == to {
+		return
+	}
+	b.state = to
+	breakerTransitions.Add(from.String()+"->"+to.String(), 1)
+	log.Printf("Cache circuit breaker changed from %s to %s", from, to)
+}
@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type flakyCache struct {
+	NoopCache
+	err   error
+	calls int
+}
+
+func (f *flakyCache) Get(ctx context.Context, key string) ([]byte, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return nil, ErrMiss
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	backend := &flakyCache{err: errors.New("connection refused")}
+	breaker := NewCircuitBreaker(backend, 2, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	// Opens after the failure threshold and stops calling the backend
+	breaker.Get(ctx, "a")
+	assert.Equal(t, StateClosed, breaker.State())
+	breaker.Get(ctx, "a")
+	assert.Equal(t, StateOpen, breaker.State())
+
+	_, err := breaker.Get(ctx, "a")
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 2, backend.calls)
+
+	// A failed probe reopens the circuit
+	now = now.Add(time.Minute)
+	breaker.Get(ctx, "a")
+	assert.Equal(t, StateOpen, breaker.State())
+	assert.Equal(t, 3, backend.calls)
+
+	// A canceled probe leaves the circuit half-open for the next probe
+	now = now.Add(time.Minute)
+	backend.err = context.Canceled
+	breaker.Get(ctx, "a")
+	assert.Equal(t, StateHalfOpen, breaker.State())
+	assert.Equal(t, 4, backend.calls)
+
+	// A successful probe closes it; misses are not failures
+	backend.err = nil
+	_, err = breaker.Get(ctx, "a")
+	assert.ErrorIs(t, err, ErrMiss)
+	assert.Equal(t, StateClosed, breaker.State())
+}
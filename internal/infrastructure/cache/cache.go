@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/reinhardjs/dot-backend-test/config"
+)
+
+// ErrMiss is returned by Get and TTL when the key is not cached.
+var ErrMiss = errors.New("cache miss")
+
+// Cache is the storage used by the usecases for read-through caching. Entries
+// may be tagged on Set so that every entry depending on the same record can be
+// dropped at once with DeleteByTag. Replace is Set conditional on key still
+// holding old, and reports whether it wrote value.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error
+	Replace(ctx context.Context, key string, old, value []byte, ttl time.Duration, tags ...string) (bool, error)
+	Delete(ctx context.Context, keys ...string) error
+	DeleteByTag(ctx context.Context, tags ...string) error
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	Incr(ctx context.Context, key string) (int64, error)
+}
+
+const (
+	DriverRedis  = "redis"
+	DriverMemory = "memory"
+	DriverNone   = "none"
+)
+
+// New builds the cache backend selected by cfg.CacheDriver. Redis is wrapped
+// in a CircuitBreaker and an unreachable server is not an error: the service
+// starts with the circuit open and serves from the database until Redis
+// comes back.
+func New(cfg *config.Config) (Cache, error) {
+	switch cfg.CacheDriver {
+	case DriverRedis, "":
+		client, err := OpenRedisClient(cfg.RedisURL, cfg.RedisTimeout)
+		if err != nil {
+			return nil, err
+		}
+		breaker := NewCircuitBreaker(client, cfg.CacheBreakerThreshold, cfg.CacheBreakerCooldown)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := client.Ping(ctx); err != nil {
+			log.Printf("Redis is unavailable, continuing without cache: %v", err)
+			breaker.Trip()
+		}
+		return breaker, nil
+	case DriverMemory:
+		return NewMemoryCache(cfg.CacheMaxEntries, cfg.CacheMaxTTL), nil
+	case DriverNone:
+		return NewNoopCache(), nil
+	}
+	return nil, fmt.Errorf("unknown cache driver %q", cfg.CacheDriver)
+}
+
+// NewLoaderFromConfig builds a Loader on top of c using the cache.ttl,
+// cache.ttl_jitter, cache.stale_ttl, cache.lock_ttl and cache.negative_ttl
+// settings.
+func NewLoaderFromConfig(c Cache, cfg *config.Config) *Loader {
+	return NewLoader(c, LoaderOptions{
+		TTL:         cfg.CacheTTL,
+		Jitter:      cfg.CacheTTLJitter,
+		StaleTTL:    cfg.CacheStaleTTL,
+		LockTTL:     cfg.CacheLockTTL,
+		NegativeTTL: cfg.CacheNegativeTTL,
+	})
+}
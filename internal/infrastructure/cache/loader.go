@@ -0,0 +1,253 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	DefaultLoaderTTL = 5 * time.Minute
+
+	lockPollInterval = 25 * time.Millisecond
+	refreshTimeout   = 10 * time.Second
+	// loadTimeout bounds a load shared by coalesced callers, which runs
+	// detached from the request of the caller that started it.
+	loadTimeout = 30 * time.Second
+)
+
+// Hits and misses per endpoint, served on /debug/vars. Stale values count as
+// hits; requests coalesced into another request's load count as misses.
+var (
+	loaderHits   = expvar.NewMap("cache_hits")
+	loaderMisses = expvar.NewMap("cache_misses")
+)
+
+// Locker is implemented by backends that can hold a short lock shared by all
+// replicas, used to let a single replica rebuild an expired entry. Lock returns
+// a token identifying the holder. Extend and Unlock only act while that token
+// still holds the lock, so a lock that expired and was taken over is left
+// alone; Extend reports whether the lock was still held.
+type Locker interface {
+	Lock(ctx context.Context, key string, ttl time.Duration) (token string, acquired bool, err error)
+	Extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error)
+	Unlock(ctx context.Context, key, token string) error
+}
+
+type LoaderOptions struct {
+	// TTL is how long a loaded value is considered fresh.
+	TTL time.Duration
+	// Jitter randomly shortens or lengthens TTL by up to this fraction so that
+	// entries loaded together do not expire together.
+	Jitter float64
+	// StaleTTL is how long past TTL a value may still be served while it is
+	// refreshed in the background.
+	StaleTTL time.Duration
+	// LockTTL enables a cross-replica lock around loads when the backend is a
+	// Locker. Zero disables it.
+	LockTTL time.Duration
+	// NegativeTTL is how long the absence of a record is cached. Zero
+	// disables negative caching.
+	NegativeTTL time.Duration
+}
+
+// LoadFunc loads a value from the source of truth along with the tags it is
+// cached under. Returning a nil value and a nil error reports that the record
+// does not exist.
+type LoadFunc func(ctx context.Context) ([]byte, []string, error)
+
+// Loader implements read-through caching with protection against cache
+// stampedes: concurrent misses for a key within a process share one load,
+// replicas optionally coordinate through a Locker, and stale values are served
+// while a single background refresh runs.
+type Loader struct {
+	cache Cache
+	opts  LoaderOptions
+	group singleflight.Group
+
+	// refreshing holds the keys with a background refresh in flight.
+	refreshing sync.Map
+}
+
+type loaderEntry struct {
+	Value      json.RawMessage `json:"value,omitempty"`
+	Missing    bool            `json:"missing,omitempty"`
+	FreshUntil time.Time       `json:"fresh_until"`
+
+	// raw is the entry as it is stored in the cache.
+	raw []byte
+}
+
+// result is the value Load returns for the entry, nil for a missing record.
+func (e loaderEntry) result() []byte {
+	if e.Missing {
+		return nil
+	}
+	return e.Value
+}
+
+func NewLoader(cache Cache, opts LoaderOptions) *Loader {
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultLoaderTTL
+	}
+	return &Loader{cache: cache, opts: opts}
+}
+
+// Load returns the cached JSON value for key, calling load on a miss. Values
+// must be valid JSON. A nil value with a nil error means the record does not
+// exist. Hits and misses are counted under endpoint.
+func (l *Loader) Load(ctx context.Context, endpoint, key string, load LoadFunc) ([]byte, error) {
+	if entry, ok := l.get(ctx, key); ok {
+		loaderHits.Add(endpoint, 1)
+		if time.Now().After(entry.FreshUntil) {
+			if _, running := l.refreshing.LoadOrStore(key, struct{}{}); !running {
+				go l.refresh(key, load)
+			}
+		}
+		return entry.result(), nil
+	}
+	loaderMisses.Add(endpoint, 1)
+
+	// The load is shared, so it must not fail for every waiter when the
+	// caller that started it goes away. Each caller still stops waiting
+	// when its own context is done.
+	results := l.group.DoChan(key, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+		defer cancel()
+		return l.loadLocked(ctx, key, nil, load)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-results:
+		if result.Err != nil || result.Val == nil {
+			return nil, result.Err
+		}
+		return result.Val.([]byte), nil
+	}
+}
+
+// refresh reloads a stale entry. It runs detached from the request that
+// noticed the entry was stale, at most once per key at a time, and is
+// coalesced with any in-flight load of the key.
+func (l *Loader) refresh(key string, load LoadFunc) {
+	defer l.refreshing.Delete(key)
+
+	ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+	defer cancel()
+
+	_, err, _ := l.group.Do(key, func() (interface{}, error) {
+		entry, ok := l.get(ctx, key)
+		if !ok {
+			// Invalidated since it was found stale; the next read loads it.
+			return nil, nil
+		}
+		// An earlier refresh may have finished since this one was queued.
+		if time.Now().Before(entry.FreshUntil) {
+			return entry.result(), nil
+		}
+		return l.loadLocked(ctx, key, entry.raw, load)
+	})
+	if err != nil {
+		log.Printf("Failed to refresh cache key %s: %v", key, err)
+	}
+}
+
+// loadLocked loads key and stores the result. stale is the entry a refresh
+// replaces, nil on a miss.
+func (l *Loader) loadLocked(ctx context.Context, key string, stale []byte, load LoadFunc) ([]byte, error) {
+	locker, ok := l.cache.(Locker)
+	if !ok || l.opts.LockTTL <= 0 {
+		return l.loadAndStore(ctx, key, stale, load)
+	}
+
+	token, acquired, err := locker.Lock(ctx, key, l.opts.LockTTL)
+	if err != nil || acquired {
+		if acquired {
+			defer locker.Unlock(context.Background(), key, token)
+		}
+		return l.loadAndStore(ctx, key, stale, load)
+	}
+
+	// Another replica is loading the key; wait for its result for at most
+	// the lock TTL before loading it ourselves.
+	deadline := time.Now().Add(l.opts.LockTTL)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+		if entry, ok := l.get(ctx, key); ok && time.Now().Before(entry.FreshUntil) {
+			return entry.result(), nil
+		}
+	}
+	return l.loadAndStore(ctx, key, stale, load)
+}
+
+func (l *Loader) loadAndStore(ctx context.Context, key string, stale []byte, load LoadFunc) ([]byte, error) {
+	value, tags, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if value == nil {
+		if l.opts.NegativeTTL > 0 {
+			entry := loaderEntry{Missing: true, FreshUntil: time.Now().Add(l.opts.NegativeTTL)}
+			if data, err := json.Marshal(entry); err == nil {
+				l.store(ctx, key, stale, data, l.opts.NegativeTTL, tags)
+			}
+		}
+		return nil, nil
+	}
+
+	ttl := l.jitteredTTL()
+	data, err := json.Marshal(loaderEntry{Value: value, FreshUntil: time.Now().Add(ttl)})
+	if err != nil {
+		return nil, err
+	}
+	l.store(ctx, key, stale, data, ttl+l.opts.StaleTTL, tags)
+	return value, nil
+}
+
+// store writes a loaded entry. A refresh only replaces the stale entry it
+// started from: if a write invalidated the key in the meantime, the value it
+// loaded may predate that write.
+func (l *Loader) store(ctx context.Context, key string, stale, data []byte, ttl time.Duration, tags []string) {
+	if stale == nil {
+		l.cache.Set(ctx, key, data, ttl, tags...)
+		return
+	}
+	l.cache.Replace(ctx, key, stale, data, ttl, tags...)
+}
+
+func (l *Loader) get(ctx context.Context, key string) (loaderEntry, bool) {
+	var entry loaderEntry
+	data, err := l.cache.Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, ErrMiss) && !errors.Is(err, ErrCircuitOpen) {
+			log.Printf("Failed to read cache key %s: %v", key, err)
+		}
+		return entry, false
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return entry, false
+	}
+	entry.raw = data
+	return entry, true
+}
+
+func (l *Loader) jitteredTTL() time.Duration {
+	if l.opts.Jitter <= 0 {
+		return l.opts.TTL
+	}
+	delta := (rand.Float64()*2 - 1) * l.opts.Jitter * float64(l.opts.TTL)
+	return l.opts.TTL + time.Duration(delta)
+}
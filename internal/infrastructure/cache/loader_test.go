@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoader(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Coalesces Concurrent Misses", func(t *testing.T) {
+		loader := NewLoader(NewMemoryCache(10, time.Minute), LoaderOptions{TTL: time.Minute})
+
+		var loads int32
+		release := make(chan struct{})
+		load := func(ctx context.Context) ([]byte, []string, error) {
+			atomic.AddInt32(&loads, 1)
+			<-release
+			return []byte(`"value"`), nil, nil
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				value, err := loader.Load(ctx, "test", "hot", load)
+				assert.NoError(t, err)
+				assert.Equal(t, `"value"`, string(value))
+			}()
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+	})
+
+	t.Run("Shared Load Survives Cancelled Caller", func(t *testing.T) {
+		loader := NewLoader(NewMemoryCache(10, time.Minute), LoaderOptions{TTL: time.Minute})
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		load := func(ctx context.Context) ([]byte, []string, error) {
+			close(started)
+			<-release
+			return []byte(`"value"`), nil, ctx.Err()
+		}
+
+		first, cancel := context.WithCancel(ctx)
+		errs := make(chan error, 1)
+		go func() {
+			_, err := loader.Load(first, "test", "shared", load)
+			errs <- err
+		}()
+		<-started
+
+		values := make(chan []byte, 1)
+		go func() {
+			value, err := loader.Load(ctx, "test", "shared", load)
+			assert.NoError(t, err)
+			values <- value
+		}()
+
+		cancel()
+		assert.ErrorIs(t, <-errs, context.Canceled)
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		assert.Equal(t, `"value"`, string(<-values))
+	})
+
+	t.Run("Serves Stale While Revalidating", func(t *testing.T) {
+		loader := NewLoader(NewMemoryCache(10, time.Minute), LoaderOptions{TTL: 10 * time.Millisecond, StaleTTL: time.Minute})
+
+		var version int32
+		load := func(ctx context.Context) ([]byte, []string, error) {
+			if atomic.AddInt32(&version, 1) == 1 {
+				return []byte(`"v1"`), nil, nil
+			}
+			return []byte(`"v2"`), nil, nil
+		}
+
+		value, err := loader.Load(ctx, "test", "key", load)
+		assert.NoError(t, err)
+		assert.Equal(t, `"v1"`, string(value))
+
+		time.Sleep(20 * time.Millisecond)
+		value, err = loader.Load(ctx, "test", "key", load)
+		assert.NoError(t, err)
+		assert.Equal(t, `"v1"`, string(value))
+
+		assert.Eventually(t, func() bool {
+			value, _ := loader.Load(ctx, "test", "key", load)
+			return string(value) == `"v2"`
+		}, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("Refreshes A Stale Key Once At A Time", func(t *testing.T) {
+		loader := NewLoader(NewMemoryCache(10, time.Minute), LoaderOptions{TTL: 10 * time.Millisecond, StaleTTL: time.Minute})
+
+		var loads int32
+		release := make(chan struct{})
+		load := func(ctx context.Context) ([]byte, []string, error) {
+			if atomic.AddInt32(&loads, 1) > 1 {
+				<-release
+			}
+			return []byte(`"value"`), nil, nil
+		}
+		loader.Load(ctx, "test", "key", load)
+		time.Sleep(20 * time.Millisecond)
+
+		before := runtime.NumGoroutine()
+		for i := 0; i < 100; i++ {
+			loader.Load(ctx, "test", "key", load)
+		}
+		assert.LessOrEqual(t, runtime.NumGoroutine()-before, 1)
+
+		close(release)
+		assert.Eventually(t, func() bool {
+			_, running := loader.refreshing.Load("key")
+			return !running
+		}, time.Second, 5*time.Millisecond)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&loads))
+	})
+
+	t.Run("Does Not Restore Invalidated Entries", func(t *testing.T) {
+		memory := NewMemoryCache(10, time.Minute)
+		loader := NewLoader(memory, LoaderOptions{TTL: 10 * time.Millisecond, StaleTTL: time.Minute})
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		var loads int32
+		load := func(ctx context.Context) ([]byte, []string, error) {
+			if atomic.AddInt32(&loads, 1) > 1 {
+				close(started)
+				<-release
+			}
+			return []byte(`"old"`), nil, nil
+		}
+		loader.Load(ctx, "test", "key", load)
+		time.Sleep(20 * time.Millisecond)
+
+		// The refresh loads the old value, then a write invalidates the key
+		loader.Load(ctx, "test", "key", load)
+		<-started
+		assert.NoError(t, memory.Delete(ctx, "key"))
+		close(release)
+
+		assert.Eventually(t, func() bool {
+			_, running := loader.refreshing.Load("key")
+			return !running
+		}, time.Second, 5*time.Millisecond)
+		_, err := memory.Get(ctx, "key")
+		assert.ErrorIs(t, err, ErrMiss)
+	})
+
+	t.Run("Caches Missing Records", func(t *testing.T) {
+		memory := NewMemoryCache(10, time.Minute)
+		loader := NewLoader(memory, LoaderOptions{TTL: time.Minute, NegativeTTL: time.Minute})
+
+		var loads int32
+		load := func(ctx context.Context) ([]byte, []string, error) {
+			atomic.AddInt32(&loads, 1)
+			return nil, nil, nil
+		}
+
+		for i := 0; i < 3; i++ {
+			value, err := loader.Load(ctx, "test", "missing", load)
+			assert.NoError(t, err)
+			assert.Nil(t, value)
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+
+		ttl, err := memory.TTL(ctx, "missing")
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, ttl, time.Minute)
+	})
+}
@@ -0,0 +1,259 @@
+package cache
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMemoryMaxEntries = 10000
+	DefaultMemoryMaxTTL     = time.Hour
+)
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+	tags      []string
+}
+
+type memoryLock struct {
+	token     string
+	expiresAt time.Time
+}
+
+// MemoryCache is an in-process LRU cache bounded both by the number of entries
+// and by a maximum TTL per entry.
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxTTL     time.Duration
+	lru        *list.List
+	entries    map[string]*list.Element
+	tags       map[string]map[string]struct{}
+	counters   map[string]int64
+	locks      map[string]memoryLock
+	lockSeq    uint64
+	now        func() time.Time
+}
+
+func NewMemoryCache(maxEntries int, maxTTL time.Duration) *MemoryCache {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMemoryMaxEntries
+	}
+	if maxTTL <= 0 {
+		maxTTL = DefaultMemoryMaxTTL
+	}
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		maxTTL:     maxTTL,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+		tags:       make(map[string]map[string]struct{}),
+		counters:   make(map[string]int64),
+		locks:      make(map[string]memoryLock),
+		now:        time.Now,
+	}
+}
+
+func (m *MemoryCache) Get(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if value, ok := m.counters[key]; ok {
+		return []byte(strconv.FormatInt(value, 10)), nil
+	}
+	entry, ok := m.lookup(key)
+	if !ok {
+		return nil, ErrMiss
+	}
+	m.lru.MoveToFront(m.entries[key])
+	return entry.value, nil
+}
+
+func (m *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	if ttl <= 0 || ttl > m.maxTTL {
+		ttl = m.maxTTL
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.counters, key)
+	m.set(key, value, ttl, tags)
+	return nil
+}
+
+func (m *MemoryCache) Replace(ctx context.Context, key string, old, value []byte, ttl time.Duration, tags ...string) (bool, error) {
+	if ttl <= 0 || ttl > m.maxTTL {
+		ttl = m.maxTTL
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.lookup(key)
+	if !ok || !bytes.Equal(entry.value, old) {
+		return false, nil
+	}
+	m.set(key, value, ttl, tags)
+	return true, nil
+}
+
+func (m *MemoryCache) set(key string, value []byte, ttl time.Duration, tags []string) {
+	if elem, ok := m.entries[key]; ok {
+		m.remove(elem)
+	}
+
+	entry := &memoryEntry{
+		key:       key,
+		value:     append([]byte(nil), value...),
+		expiresAt: m.now().Add(ttl),
+		tags:      tags,
+	}
+	m.entries[key] = m.lru.PushFront(entry)
+	for _, tag := range tags {
+		if m.tags[tag] == nil {
+			m.tags[tag] = make(map[string]struct{})
+		}
+		m.tags[tag][key] = struct{}{}
+	}
+
+	for m.lru.Len() > m.maxEntries {
+		m.remove(m.lru.Back())
+	}
+}
+
+func (m *MemoryCache) Delete(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		delete(m.counters, key)
+		if elem, ok := m.entries[key]; ok {
+			m.remove(elem)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryCache) DeleteByTag(ctx context.Context, tags ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range m.tags[tag] {
+			if elem, ok := m.entries[key]; ok {
+				m.remove(elem)
+			}
+		}
+		delete(m.tags, tag)
+	}
+	return nil
+}
+
+func (m *MemoryCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.counters[key]; ok {
+		return -1, nil
+	}
+	entry, ok := m.lookup(key)
+	if !ok {
+		return 0, ErrMiss
+	}
+	return entry.expiresAt.Sub(m.now()), nil
+}
+
+// Incr increments the integer stored at key, starting from zero. Counters are
+// kept apart from entries and never expire or get evicted: list generations
+// must not fall back to a value whose pages may still be cached.
+func (m *MemoryCache) Incr(ctx context.Context, key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	value, ok := m.counters[key]
+	if !ok {
+		if entry, found := m.lookup(key); found {
+			parsed, err := strconv.ParseInt(string(entry.value), 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("value at %s is not an integer", key)
+			}
+			value = parsed
+			m.remove(m.entries[key])
+		}
+	}
+	value++
+
+	m.counters[key] = value
+	return value, nil
+}
+
+// Lock acquires key until Unlock or until ttl elapses. Locks are kept apart
+// from entries, so they are never evicted, and only exclude callers within
+// the same process.
+func (m *MemoryCache) Lock(ctx context.Context, key string, ttl time.Duration) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if lock, ok := m.locks[key]; ok && m.now().Before(lock.expiresAt) {
+		return "", false, nil
+	}
+	m.lockSeq++
+	token := strconv.FormatUint(m.lockSeq, 10)
+	m.locks[key] = memoryLock{token: token, expiresAt: m.now().Add(ttl)}
+	return token, true, nil
+}
+
+func (m *MemoryCache) Extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lock, ok := m.locks[key]
+	if !ok || lock.token != token || !m.now().Before(lock.expiresAt) {
+		return false, nil
+	}
+	m.locks[key] = memoryLock{token: token, expiresAt: m.now().Add(ttl)}
+	return true, nil
+}
+
+func (m *MemoryCache) Unlock(ctx context.Context, key, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if lock, ok := m.locks[key]; ok && lock.token == token {
+		delete(m.locks, key)
+	}
+	return nil
+}
+
+// lookup returns the live entry for key, evicting it if it has expired.
+func (m *MemoryCache) lookup(key string) (*memoryEntry, bool) {
+	elem, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*memoryEntry)
+	if !m.now().Before(entry.expiresAt) {
+		m.remove(elem)
+		return nil, false
+	}
+	return entry, true
+}
+
+func (m *MemoryCache) remove(elem *list.Element) {
+	entry := m.lru.Remove(elem).(*memoryEntry)
+	delete(m.entries, entry.key)
+	for _, tag := range entry.tags {
+		delete(m.tags[tag], entry.key)
+		if len(m.tags[tag]) == 0 {
+			delete(m.tags, tag)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Evicts Least Recently Used", func(t *testing.T) {
+		c := NewMemoryCache(2, time.Minute)
+		c.Set(ctx, "a", []byte("1"), time.Minute)
+		c.Set(ctx, "b", []byte("2"), time.Minute)
+		c.Get(ctx, "a")
+		c.Set(ctx, "c", []byte("3"), time.Minute)
+
+		_, err := c.Get(ctx, "b")
+		assert.ErrorIs(t, err, ErrMiss)
+		value, err := c.Get(ctx, "a")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("1"), value)
+	})
+
+	t.Run("Expires Entries", func(t *testing.T) {
+		now := time.Now()
+		c := NewMemoryCache(10, time.Minute)
+		c.now = func() time.Time { return now }
+
+		c.Set(ctx, "short", []byte("1"), time.Second)
+		c.Set(ctx, "capped", []byte("2"), time.Hour)
+
+		ttl, err := c.TTL(ctx, "capped")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Minute, ttl)
+
+		now = now.Add(2 * time.Second)
+		_, err = c.Get(ctx, "short")
+		assert.ErrorIs(t, err, ErrMiss)
+		_, err = c.Get(ctx, "capped")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Deletes By Tag", func(t *testing.T) {
+		c := NewMemoryCache(10, time.Minute)
+		c.Set(ctx, "product:1", []byte("1"), time.Minute, "category:1")
+		c.Set(ctx, "product:2", []byte("2"), time.Minute, "category:1")
+		c.Set(ctx, "product:3", []byte("3"), time.Minute, "category:2")
+
+		assert.NoError(t, c.DeleteByTag(ctx, "category:1"))
+
+		_, err := c.Get(ctx, "product:1")
+		assert.ErrorIs(t, err, ErrMiss)
+		_, err = c.Get(ctx, "product:2")
+		assert.ErrorIs(t, err, ErrMiss)
+		_, err = c.Get(ctx, "product:3")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Replaces Only The Expected Value", func(t *testing.T) {
+		c := NewMemoryCache(10, time.Minute)
+		c.Set(ctx, "key", []byte("1"), time.Minute)
+
+		replaced, err := c.Replace(ctx, "key", []byte("0"), []byte("2"), time.Minute)
+		assert.NoError(t, err)
+		assert.False(t, replaced)
+		replaced, _ = c.Replace(ctx, "key", []byte("1"), []byte("2"), time.Minute)
+		assert.True(t, replaced)
+		replaced, _ = c.Replace(ctx, "missing", nil, []byte("2"), time.Minute)
+		assert.False(t, replaced)
+
+		value, _ := c.Get(ctx, "key")
+		assert.Equal(t, []byte("2"), value)
+	})
+
+	t.Run("Locks Until Unlocked Or Expired", func(t *testing.T) {
+		now := time.Now()
+		c := NewMemoryCache(10, time.Minute)
+		c.now = func() time.Time { return now }
+
+		token, acquired, err := c.Lock(ctx, "key", time.Second)
+		assert.NoError(t, err)
+		assert.True(t, acquired)
+		_, acquired, _ = c.Lock(ctx, "key", time.Second)
+		assert.False(t, acquired)
+
+		assert.NoError(t, c.Unlock(ctx, "key", token))
+		expired, acquired, _ := c.Lock(ctx, "key", time.Second)
+		assert.True(t, acquired)
+
+		now = now.Add(2 * time.Second)
+		_, acquired, _ = c.Lock(ctx, "key", time.Second)
+		assert.True(t, acquired)
+
+		// The holder of an expired lock cannot release its successor's
+		assert.NoError(t, c.Unlock(ctx, "key", expired))
+		_, acquired, _ = c.Lock(ctx, "key", time.Second)
+		assert.False(t, acquired)
+		extended, err := c.Extend(ctx, "key", expired, time.Second)
+		assert.NoError(t, err)
+		assert.False(t, extended)
+	})
+
+	t.Run("Extends Held Locks", func(t *testing.T) {
+		now := time.Now()
+		c := NewMemoryCache(10, time.Minute)
+		c.now = func() time.Time { return now }
+
+		token, _, _ := c.Lock(ctx, "key", time.Second)
+		now = now.Add(900 * time.Millisecond)
+		extended, err := c.Extend(ctx, "key", token, time.Second)
+		assert.NoError(t, err)
+		assert.True(t, extended)
+
+		now = now.Add(900 * time.Millisecond)
+		_, acquired, _ := c.Lock(ctx, "key", time.Second)
+		assert.False(t, acquired)
+	})
+
+	t.Run("Pins Counters", func(t *testing.T) {
+		now := time.Now()
+		c := NewMemoryCache(2, time.Minute)
+		c.now = func() time.Time { return now }
+
+		_, err := c.Incr(ctx, "generation")
+		assert.NoError(t, err)
+		value, err := c.Incr(ctx, "generation")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), value)
+
+		c.Set(ctx, "a", []byte("1"), time.Minute)
+		c.Set(ctx, "b", []byte("2"), time.Minute)
+		c.Set(ctx, "c", []byte("3"), time.Minute)
+		now = now.Add(2 * time.Hour)
+
+		stored, err := c.Get(ctx, "generation")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("2"), stored)
+	})
+}
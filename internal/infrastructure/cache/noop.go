@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// NoopCache never stores anything, so every read goes to the database.
+type NoopCache struct{}
+
+func NewNoopCache() *NoopCache {
+	return &NoopCache{}
+}
+
+func (NoopCache) Get(ctx context.Context, key string) ([]byte, error) {
+	return nil, ErrMiss
+}
+
+func (NoopCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	return nil
+}
+
+func (NoopCache) Replace(ctx context.Context, key string, old, value []byte, ttl time.Duration, tags ...string) (bool, error) {
+	return false, nil
+}
+
+func (NoopCache) Delete(ctx context.Context, keys ...string) error {
+	return nil
+}
+
+func (NoopCache) DeleteByTag(ctx context.Context, tags ...string) error {
+	return nil
+}
+
+func (NoopCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return 0, ErrMiss
+}
+
+func (NoopCache) Incr(ctx context.Context, key string) (int64, error) {
+	return 0, nil
+}
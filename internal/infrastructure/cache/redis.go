@@ -2,6 +2,8 @@ package cache
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"time"
 
@@ -12,37 +14,196 @@ type RedisClient struct {
 	Client *redis.Client
 }
 
+// unlockScript deletes a lock only if it still holds the caller's token, so a
+// lock that expired and was taken over by another holder is left alone.
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// extendScript renews a lock for ARGV[2] milliseconds only if it still holds
+// the caller's token ARGV[1].
+var extendScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// tagLua defines tag(set, member, ttl), which adds member to the tag set and
+// makes the set live at least ttl milliseconds, 0 meaning forever. The TTL of
+// the set is only ever extended, so the set outlives every member it lists.
+const tagLua = `
+local function tag(set, member, ttl)
+	local current = redis.call("PTTL", set)
+	redis.call("SADD", set, member)
+	if ttl == "0" then
+		redis.call("PERSIST", set)
+	elseif current == -2 or (current >= 0 and current < tonumber(ttl)) then
+		redis.call("PEXPIRE", set, ttl)
+	end
+end
+`
+
+// tagScript tags ARGV[2] with the tag set KEYS[1] for ARGV[1] milliseconds.
+var tagScript = redis.NewScript(tagLua + `
+tag(KEYS[1], ARGV[2], ARGV[1])
+return 0
+`)
+
+// replaceScript sets KEYS[1] to ARGV[2] for ARGV[3] milliseconds, 0 meaning
+// forever, and tags it with the tag sets KEYS[2..], but only if it still holds
+// ARGV[1].
+var replaceScript = redis.NewScript(tagLua + `
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if ARGV[3] == "0" then
+	redis.call("SET", KEYS[1], ARGV[2])
+else
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+end
+for i = 2, #KEYS do
+	tag(KEYS[i], KEYS[1], ARGV[3])
+end
+return 1
+`)
+
 func NewRedisClient(redisURL string) (*RedisClient, error) {
+	client, err := OpenRedisClient(redisURL, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx); err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	return client, nil
+}
+
+// OpenRedisClient creates a client without checking that Redis is reachable.
+// A non-zero timeout bounds dialing, reads and writes so that an unresponsive
+// server fails calls quickly instead of stalling requests.
+func OpenRedisClient(redisURL string, timeout time.Duration) (*RedisClient, error) {
 	opts, err := redis.ParseURL(redisURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
 	}
+	if timeout > 0 {
+		opts.DialTimeout = timeout
+		opts.ReadTimeout = timeout
+		opts.WriteTimeout = timeout
+	}
 
-	client := redis.NewClient(opts)
+	return &RedisClient{Client: redis.NewClient(opts)}, nil
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+func (r *RedisClient) Ping(ctx context.Context) error {
+	return r.Client.Ping(ctx).Err()
+}
 
-	_, err = client.Ping(ctx).Result()
+// Set stores value under key and adds key to the set of every tag. A tag set
+// expires with its longest-lived member, never earlier, so DeleteByTag finds
+// every key that is still cached.
+func (r *RedisClient) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	_, err := r.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key, value, ttl)
+		for _, tag := range tags {
+			tagScript.Eval(ctx, pipe, []string{tagKey(tag)}, ttl.Milliseconds(), key)
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *RedisClient) Replace(ctx context.Context, key string, old, value []byte, ttl time.Duration, tags ...string) (bool, error) {
+	keys := []string{key}
+	for _, tag := range tags {
+		keys = append(keys, tagKey(tag))
+	}
+	replaced, err := replaceScript.Run(ctx, r.Client, keys, old, value, ttl.Milliseconds()).Int()
+	return replaced == 1, err
+}
+
+func (r *RedisClient) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := r.Client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrMiss
+	}
+	return value, err
+}
+
+func (r *RedisClient) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.Client.Del(ctx, keys...).Err()
+}
+
+// DeleteByTag deletes every key tagged with one of tags along with the tag
+// sets themselves.
+func (r *RedisClient) DeleteByTag(ctx context.Context, tags ...string) error {
+	var keys []string
+	for _, tag := range tags {
+		members, err := r.Client.SMembers(ctx, tagKey(tag)).Result()
+		if err != nil {
+			return err
+		}
+		keys = append(keys, members...)
+		keys = append(keys, tagKey(tag))
+	}
+	return r.Delete(ctx, keys...)
+}
+
+func (r *RedisClient) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.Client.TTL(ctx, key).Result()
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+		return 0, err
+	}
+	// Redis reports -2 for a missing key and -1 for a key without expiry.
+	if ttl == -2 {
+		return 0, ErrMiss
+	}
+	return ttl, nil
+}
+
+func (r *RedisClient) Incr(ctx context.Context, key string) (int64, error) {
+	return r.Client.Incr(ctx, key).Result()
+}
+
+func (r *RedisClient) Lock(ctx context.Context, key string, ttl time.Duration) (string, bool, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", false, err
 	}
+	token := hex.EncodeToString(b)
 
-	return &RedisClient{Client: client}, nil
+	acquired, err := r.Client.SetNX(ctx, lockKey(key), token, ttl).Result()
+	if err != nil || !acquired {
+		return "", false, err
+	}
+	return token, true, nil
 }
 
-func (r *RedisClient) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
-	return r.Client.Set(ctx, key, value, expiration).Err()
+func (r *RedisClient) Extend(ctx context.Context, key, token string, ttl time.Duration) (bool, error) {
+	extended, err := extendScript.Run(ctx, r.Client, []string{lockKey(key)}, token, ttl.Milliseconds()).Int()
+	return extended == 1, err
 }
 
-func (r *RedisClient) Get(ctx context.Context, key string) (string, error) {
-	return r.Client.Get(ctx, key).Result()
+func (r *RedisClient) Unlock(ctx context.Context, key, token string) error {
+	return unlockScript.Run(ctx, r.Client, []string{lockKey(key)}, token).Err()
 }
 
-func (r *RedisClient) Delete(ctx context.Context, key string) error {
-	return r.Client.Del(ctx, key).Err()
+func lockKey(key string) string {
+	return "lock:" + key
 }
 
-func (r *RedisClient) FlushDB(ctx context.Context) error {
-	return r.Client.FlushDB(ctx).Err()
+func tagKey(tag string) string {
+	return "tag:" + tag
 }
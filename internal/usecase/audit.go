@@ -0,0 +1,89 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/reinhardjs/dot-backend-test/internal/domain/entity"
+	"github.com/reinhardjs/dot-backend-test/internal/domain/repository"
+	"github.com/reinhardjs/dot-backend-test/pkg/audit"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+)
+
+// auditChange is a write to a single record, given by snapshots of the
+// record before and after it.
+type auditChange struct {
+	id     uint
+	before map[string]interface{}
+	after  map[string]interface{}
+}
+
+// recordAudit writes an audit entry for every change, in the transaction of
+// tx, so the entries commit or roll back with the writes they describe. The
+// actor and request ID are taken from ctx. Updates that changed no audited
+// field are skipped.
+func recordAudit(ctx context.Context, tx repository.UnitOfWork, entityType, action string, changes ...auditChange) error {
+	entries := make([]entity.AuditEntry, 0, len(changes))
+	for _, change := range changes {
+		diff := audit.Diff(change.before, change.after)
+		if len(diff) == 0 && action == entity.AuditUpdate {
+			continue
+		}
+		data, err := json.Marshal(diff)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entity.AuditEntry{
+			EntityType: entityType,
+			EntityID:   change.id,
+			Action:     action,
+			Actor:      audit.Actor(ctx),
+			RequestID:  audit.RequestID(ctx),
+			Changes:    string(data),
+		})
+	}
+	return tx.Audit().Create(ctx, entries)
+}
+
+// productSnapshot returns the audited fields of product, keyed like the API.
+func productSnapshot(product entity.Product) map[string]interface{} {
+	var externalKey interface{}
+	if product.ExternalKey != nil {
+		externalKey = *product.ExternalKey
+	}
+	return map[string]interface{}{
+		"name":         product.Name,
+		"price":        product.Price,
+		"category_id":  product.CategoryID,
+		"external_key": externalKey,
+	}
+}
+
+func categorySnapshot(category entity.Category) map[string]interface{} {
+	var parentID interface{}
+	if category.ParentID != nil {
+		parentID = *category.ParentID
+	}
+	return map[string]interface{}{
+		"name":      category.Name,
+		"parent_id": parentID,
+	}
+}
+
+// loadHistory returns a page of the audit entries of a record. The field
+// filter must name an audited field, i.e. a key of snapshot.
+func loadHistory(ctx context.Context, uow repository.UnitOfWork, query repository.AuditQuery, snapshot map[string]interface{}) ([]entity.AuditEntry, int64, error) {
+	if query.Field != "" {
+		if _, ok := snapshot[query.Field]; !ok {
+			var fields []string
+			for field := range snapshot {
+				fields = append(fields, field)
+			}
+			slices.Sort(fields)
+			return nil, 0, errors.InvalidField("field", "field must be one of %s", strings.Join(fields, ", "))
+		}
+	}
+	return uow.Audit().FindByQuery(ctx, query)
+}
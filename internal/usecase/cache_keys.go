@@ -0,0 +1,88 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/reinhardjs/dot-backend-test/internal/infrastructure/cache"
+)
+
+const (
+	productsList   = "products"
+	categoriesList = "categories"
+)
+
+func productCacheKey(id uint) string {
+	return fmt.Sprintf("product:%d", id)
+}
+
+func categoryCacheKey(id uint) string {
+	return fmt.Sprintf("category:%d", id)
+}
+
+// categoryTag groups the cached entries that embed a category, i.e. the
+// category itself and the products belonging to it.
+func categoryTag(id uint) string {
+	return fmt.Sprintf("category:%d", id)
+}
+
+func generationKey(list string) string {
+	return list + ":generation"
+}
+
+// listCacheKey derives the cache key of a list query from the current
+// generation of the list and a fingerprint of the normalized query. Writes
+// bump the generation, so every page cached before the write is simply never
+// read again and ages out on its own. ok is false when the generation cannot
+// be read, in which case the list should not be cached.
+func listCacheKey(ctx context.Context, c cache.Cache, list string, query interface{}) (string, bool) {
+	generation := "0"
+	value, err := c.Get(ctx, generationKey(list))
+	switch {
+	case err == nil:
+		generation = string(value)
+	case !errors.Is(err, cache.ErrMiss):
+		return "", false
+	}
+
+	fingerprint, err := json.Marshal(query)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(fingerprint)
+	return list + ":list:" + generation + ":" + hex.EncodeToString(sum[:16]), true
+}
+
+func bumpGeneration(ctx context.Context, c cache.Cache, lists ...string) {
+	for _, list := range lists {
+		c.Incr(ctx, generationKey(list))
+	}
+}
+
+// loadList serves a list query through the loader under its versioned key,
+// falling back to load when the list generation is unavailable.
+func loadList[T any](ctx context.Context, c cache.Cache, loader *cache.Loader, endpoint, list string, query interface{}, load func(ctx context.Context) (T, error)) (T, error) {
+	key, ok := listCacheKey(ctx, c, list, query)
+	if !ok {
+		return load(ctx)
+	}
+
+	var result T
+	cached, err := loader.Load(ctx, endpoint, key, func(ctx context.Context) ([]byte, []string, error) {
+		result, err := load(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := json.Marshal(result)
+		return data, nil, err
+	})
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(cached, &result)
+	return result, err
+}
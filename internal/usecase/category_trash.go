@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/reinhardjs/dot-backend-test/internal/domain/entity"
+	"github.com/reinhardjs/dot-backend-test/internal/domain/repository"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+)
+
+// GetDeletedCategories lists the categories in the trash. They are never
+// cached.
+func (u *categoryUsecase) GetDeletedCategories(ctx context.Context, page, pageSize int) ([]entity.Category, int64, error) {
+	return u.uow.Categories().FindDeleted(ctx, page, pageSize)
+}
+
+// RestoreCategory takes a category out of the trash. Its parent has to be
+// restored first. Products deleted along with the category stay in the trash.
+func (u *categoryUsecase) RestoreCategory(ctx context.Context, id uint) (*entity.Category, error) {
+	var category *entity.Category
+	err := u.uow.Do(ctx, func(tx repository.UnitOfWork) error {
+		if err := tx.Categories().LockTree(ctx); err != nil {
+			return err
+		}
+		deleted, err := tx.Categories().FindDeletedByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if deleted.ParentID != nil {
+			_, err := tx.Categories().GetByID(ctx, *deleted.ParentID)
+			if errors.KindOf(err) == errors.KindNotFound {
+				return errors.Conflict("parent category %d is deleted, restore it first", *deleted.ParentID).WithCode("parent_deleted")
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Categories().Restore(ctx, id); err != nil {
+			return err
+		}
+		if category, err = tx.Categories().GetByID(ctx, id); err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, entity.AuditCategory, entity.AuditRestore, auditChange{id: id, after: categorySnapshot(*category)}); err != nil {
+			return err
+		}
+		// Also drops the cached "not found" marker.
+		tx.AfterCommit(func() { u.invalidateCache(ctx, id) })
+		return nil
+	})
+	return category, err
+}
+
+// PurgeCategory permanently deletes a category in the trash. Categories that
+// products or subcategories, even deleted ones, still refer to are kept.
+func (u *categoryUsecase) PurgeCategory(ctx context.Context, id uint) error {
+	return u.uow.Do(ctx, func(tx repository.UnitOfWork) error {
+		if err := tx.Categories().LockTree(ctx); err != nil {
+			return err
+		}
+		if _, err := tx.Categories().FindDeletedByID(ctx, id); err != nil {
+			return err
+		}
+		count, err := tx.Categories().CountReferences(ctx, id)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.Conflict("category is still referred to by %d products or subcategories", count).WithCode("category_in_use")
+		}
+		return tx.Categories().Purge(ctx, id)
+	})
+}
+
+// PurgeDeletedCategories permanently deletes the categories that were moved
+// to the trash before the given time and are no longer referred to.
+func (u *categoryUsecase) PurgeDeletedCategories(ctx context.Context, before time.Time) (int64, error) {
+	return u.uow.Categories().PurgeDeletedBefore(ctx, before)
+}
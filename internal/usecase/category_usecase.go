@@ -3,107 +3,345 @@ package usecase
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"slices"
 	"time"
 
-	"github.com/go-redis/redis/v8"
 	"github.com/reinhardjs/dot-backend-test/internal/domain/entity"
 	"github.com/reinhardjs/dot-backend-test/internal/domain/repository"
+	"github.com/reinhardjs/dot-backend-test/internal/infrastructure/cache"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
 	"gorm.io/gorm"
 )
 
 type CategoryUsecase interface {
-	CreateCategory(category *entity.Category) error
-	GetCategoryByID(id uint) (*entity.Category, error)
-	UpdateCategory(category *entity.Category) error
-	DeleteCategory(id uint) error
-	GetAllCategories() ([]entity.Category, error)
+	CreateCategory(ctx context.Context, category *entity.Category) error
+	GetCategoryByID(ctx context.Context, id uint) (*entity.Category, error)
+	GetCategoryForUpdate(ctx context.Context, id uint) (*entity.Category, error)
+	UpdateCategory(ctx context.Context, category *entity.Category, fields ...string) error
+	DeleteCategory(ctx context.Context, id uint, version uint, opts DeleteCategoryOptions) error
+	GetAllCategories(ctx context.Context) ([]entity.Category, error)
+	GetCategoriesAfter(ctx context.Context, sort repository.SortField, after *repository.Keyset, limit int) ([]entity.Category, error)
+	GetCategoryStats(ctx context.Context) (repository.ListStats, error)
+	GetCategoryChildren(ctx context.Context, id uint) ([]entity.Category, error)
+	GetCategoryAncestors(ctx context.Context, id uint) ([]entity.Category, error)
+	GetCategoryDescendants(ctx context.Context, id uint) ([]entity.Category, error)
+	GetDeletedCategories(ctx context.Context, page, pageSize int) ([]entity.Category, int64, error)
+	RestoreCategory(ctx context.Context, id uint) (*entity.Category, error)
+	PurgeCategory(ctx context.Context, id uint) error
+	PurgeDeletedCategories(ctx context.Context, before time.Time) (int64, error)
+	GetCategoryHistory(ctx context.Context, query repository.AuditQuery) ([]entity.AuditEntry, int64, error)
+}
+
+// Policies for the products of a deleted category.
+const (
+	OnProductsRestrict = "restrict"
+	OnProductsReassign = "reassign"
+	OnProductsCascade  = "cascade"
+)
+
+// DeleteCategoryOptions decides what happens to the products of a deleted
+// category: OnProductsRestrict refuses to delete a category that still has
+// products, OnProductsReassign moves them to TargetCategoryID and
+// OnProductsCascade deletes them along with the category.
+type DeleteCategoryOptions struct {
+	OnProducts       string
+	TargetCategoryID uint
 }
 
 type categoryUsecase struct {
-	repo  repository.CategoryRepository
-	cache *redis.Client
-	db    *gorm.DB
+	uow    repository.UnitOfWork
+	cache  cache.Cache
+	loader *cache.Loader
 }
 
-func NewCategoryUsecase(db *gorm.DB, cache *redis.Client) CategoryUsecase {
+func NewCategoryUsecase(db *gorm.DB, cache cache.Cache, loader *cache.Loader) CategoryUsecase {
 	return &categoryUsecase{
-		repo:  repository.NewCategoryRepository(db),
-		cache: cache,
-		db:    db,
+		uow:    repository.NewUnitOfWork(db),
+		cache:  cache,
+		loader: loader,
 	}
 }
 
-func (u *categoryUsecase) CreateCategory(category *entity.Category) error {
-	err := u.db.Transaction(func(tx *gorm.DB) error {
-		if err := u.repo.Create(category); err != nil {
+func (u *categoryUsecase) CreateCategory(ctx context.Context, category *entity.Category) error {
+	return u.uow.Do(ctx, func(tx repository.UnitOfWork) error {
+		if err := checkParent(ctx, tx, category); err != nil {
 			return err
 		}
+		if err := tx.Categories().Create(ctx, category); err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, entity.AuditCategory, entity.AuditCreate, auditChange{id: category.ID, after: categorySnapshot(*category)}); err != nil {
+			return err
+		}
+		// Also drops a cached "not found" marker for the new ID.
+		tx.AfterCommit(func() { u.invalidateCache(ctx, category.ID) })
 		return nil
 	})
-	if err != nil {
-		return err
-	}
-	u.invalidateCache()
-	return nil
 }
 
-func (u *categoryUsecase) GetCategoryByID(id uint) (*entity.Category, error) {
-	ctx := context.Background()
-	cacheKey := fmt.Sprintf("category:%d", id)
-
-	cachedCategory, err := u.cache.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var category entity.Category
-		if err := json.Unmarshal([]byte(cachedCategory), &category); err == nil {
-			return &category, nil
+func (u *categoryUsecase) GetCategoryByID(ctx context.Context, id uint) (*entity.Category, error) {
+	cachedCategory, err := u.loader.Load(ctx, "categories.get", categoryCacheKey(id), func(ctx context.Context) ([]byte, []string, error) {
+		category, err := u.uow.Categories().GetByID(ctx, id)
+		if errors.KindOf(err) == errors.KindNotFound {
+			return nil, nil, nil
 		}
+		if err != nil {
+			return nil, nil, err
+		}
+		categoryJSON, err := json.Marshal(category)
+		return categoryJSON, []string{categoryTag(id)}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cachedCategory == nil {
+		return nil, errors.NotFound("category not found")
 	}
 
-	category, err := u.repo.GetByID(id)
-	if err != nil {
+	var category entity.Category
+	if err := json.Unmarshal(cachedCategory, &category); err != nil {
 		return nil, err
 	}
+	return &category, nil
+}
 
-	categoryJSON, _ := json.Marshal(category)
-	u.cache.Set(ctx, cacheKey, categoryJSON, time.Minute*5)
+// GetCategoryForUpdate reads the category from the database, bypassing the
+// cache, so conditional writes compare against its current version.
+func (u *categoryUsecase) GetCategoryForUpdate(ctx context.Context, id uint) (*entity.Category, error) {
+	return u.uow.Categories().GetByID(ctx, id)
+}
 
-	return category, nil
+// UpdateCategory writes the given fields of category. It does nothing when no fields
+// are given.
+func (u *categoryUsecase) UpdateCategory(ctx context.Context, category *entity.Category, fields ...string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return u.uow.Do(ctx, func(tx repository.UnitOfWork) error {
+		if slices.Contains(fields, "ParentID") {
+			if err := checkParent(ctx, tx, category); err != nil {
+				return err
+			}
+		}
+		before, err := tx.Categories().GetByID(ctx, category.ID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Categories().Update(ctx, category, fields...); err != nil {
+			return err
+		}
+		change := auditChange{id: category.ID, before: categorySnapshot(*before), after: categorySnapshot(*category)}
+		if err := recordAudit(ctx, tx, entity.AuditCategory, entity.AuditUpdate, change); err != nil {
+			return err
+		}
+		tx.AfterCommit(func() { u.invalidateCache(ctx, category.ID) })
+		return nil
+	})
 }
 
-func (u *categoryUsecase) UpdateCategory(category *entity.Category) error {
-	err := u.db.Transaction(func(tx *gorm.DB) error {
-		if err := u.repo.Update(category); err != nil {
+// DeleteCategory deletes the category and handles its products as opts say,
+// all in one transaction. Categories that still have subcategories are never
+// deleted.
+func (u *categoryUsecase) DeleteCategory(ctx context.Context, id uint, version uint, opts DeleteCategoryOptions) error {
+	return u.uow.Do(ctx, func(tx repository.UnitOfWork) error {
+		if err := tx.Categories().LockTree(ctx); err != nil {
+			return err
+		}
+		children, err := tx.Categories().Children(ctx, id)
+		if err != nil {
+			return err
+		}
+		if len(children) > 0 {
+			return errors.Conflict("category has %d subcategories", len(children)).WithCode("category_has_children")
+		}
+
+		if err := u.handleProducts(ctx, tx, id, opts); err != nil {
+			return err
+		}
+		before, err := tx.Categories().GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Categories().Delete(ctx, id, version); err != nil {
 			return err
 		}
+		if err := recordAudit(ctx, tx, entity.AuditCategory, entity.AuditDelete, auditChange{id: id, before: categorySnapshot(*before)}); err != nil {
+			return err
+		}
+		tx.AfterCommit(func() { u.invalidateCache(ctx, id) })
 		return nil
 	})
+}
+
+// handleProducts applies the OnProducts policy of opts to the products of
+// category id and audits the products it changes. The categories stay
+// locked, so no products can be added to them before the category is
+// deleted.
+func (u *categoryUsecase) handleProducts(ctx context.Context, tx repository.UnitOfWork, id uint, opts DeleteCategoryOptions) error {
+	ids := []uint{id}
+	if opts.OnProducts == OnProductsReassign {
+		if opts.TargetCategoryID == id {
+			return errors.InvalidField("target_category_id", "target_category_id must differ from the deleted category")
+		}
+		ids = append(ids, opts.TargetCategoryID)
+	}
+	locked, err := tx.Categories().Lock(ctx, ids...)
 	if err != nil {
 		return err
 	}
-	u.invalidateCache()
-	return nil
-}
+	if !slices.ContainsFunc(locked, func(c entity.Category) bool { return c.ID == id }) {
+		return errors.NotFound("category not found")
+	}
 
-func (u *categoryUsecase) DeleteCategory(id uint) error {
-	err := u.db.Transaction(func(tx *gorm.DB) error {
-		if err := u.repo.Delete(id); err != nil {
+	switch opts.OnProducts {
+	case OnProductsReassign:
+		if len(locked) < 2 {
+			return errors.InvalidField("target_category_id", "category %d does not exist", opts.TargetCategoryID)
+		}
+		products, err := tx.Products().FindByCategory(ctx, id)
+		if err != nil {
 			return err
 		}
-		return nil
+		if _, err := tx.Products().ReassignCategory(ctx, id, opts.TargetCategoryID); err != nil {
+			return err
+		}
+		changes := make([]auditChange, len(products))
+		for i, product := range products {
+			before := productSnapshot(product)
+			product.CategoryID = opts.TargetCategoryID
+			changes[i] = auditChange{id: product.ID, before: before, after: productSnapshot(product)}
+		}
+		return recordAudit(ctx, tx, entity.AuditProduct, entity.AuditUpdate, changes...)
+	case OnProductsCascade:
+		products, err := tx.Products().FindByCategory(ctx, id)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Products().DeleteByCategory(ctx, id); err != nil {
+			return err
+		}
+		changes := make([]auditChange, len(products))
+		for i, product := range products {
+			changes[i] = auditChange{id: product.ID, before: productSnapshot(product)}
+		}
+		return recordAudit(ctx, tx, entity.AuditProduct, entity.AuditDelete, changes...)
+	}
+
+	count, err := tx.Products().CountByCategory(ctx, id)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.Conflict("category still has %d products", count).WithCode("category_has_products")
+	}
+	return nil
+}
+
+func (u *categoryUsecase) GetAllCategories(ctx context.Context) ([]entity.Category, error) {
+	return loadList(ctx, u.cache, u.loader, "categories.list", categoriesList, "all", func(ctx context.Context) ([]entity.Category, error) {
+		return u.uow.Categories().GetAll(ctx)
+	})
+}
+
+func (u *categoryUsecase) GetCategoriesAfter(ctx context.Context, sort repository.SortField, after *repository.Keyset, limit int) ([]entity.Category, error) {
+	key := struct {
+		Sort  repository.SortField
+		After *repository.Keyset
+		Limit int
+	}{sort, after, limit}
+
+	return loadList(ctx, u.cache, u.loader, "categories.list", categoriesList, key, func(ctx context.Context) ([]entity.Category, error) {
+		return u.uow.Categories().FindByKeyset(ctx, sort, after, limit)
+	})
+}
+
+func (u *categoryUsecase) GetCategoryStats(ctx context.Context) (repository.ListStats, error) {
+	return loadList(ctx, u.cache, u.loader, "categories.stats", categoriesList, "stats", func(ctx context.Context) (repository.ListStats, error) {
+		return u.uow.Categories().Stats(ctx)
+	})
+}
+
+func (u *categoryUsecase) GetCategoryChildren(ctx context.Context, id uint) ([]entity.Category, error) {
+	return u.loadRelatives(ctx, "categories.children", id, u.uow.Categories().Children)
+}
+
+// GetCategoryAncestors returns the breadcrumbs of a category: its ancestors,
+// starting at the root.
+func (u *categoryUsecase) GetCategoryAncestors(ctx context.Context, id uint) ([]entity.Category, error) {
+	return u.loadRelatives(ctx, "categories.ancestors", id, u.uow.Categories().Ancestors)
+}
+
+func (u *categoryUsecase) GetCategoryDescendants(ctx context.Context, id uint) ([]entity.Category, error) {
+	return u.loadRelatives(ctx, "categories.descendants", id, u.uow.Categories().Descendants)
+}
+
+// loadRelatives loads categories related to category id with find, cached
+// alongside the category lists. It fails if the category does not exist.
+func (u *categoryUsecase) loadRelatives(ctx context.Context, endpoint string, id uint, find func(ctx context.Context, id uint) ([]entity.Category, error)) ([]entity.Category, error) {
+	if _, err := u.GetCategoryByID(ctx, id); err != nil {
+		return nil, err
+	}
+	key := struct {
+		Relation string
+		ID       uint
+	}{endpoint, id}
+
+	return loadList(ctx, u.cache, u.loader, endpoint, categoriesList, key, func(ctx context.Context) ([]entity.Category, error) {
+		return find(ctx, id)
 	})
+}
+
+// checkParent verifies that the parent of category exists and that the
+// category is not moved below itself. It locks the hierarchy for the rest of
+// the transaction, so the check stays valid until the write is committed.
+func checkParent(ctx context.Context, tx repository.UnitOfWork, category *entity.Category) error {
+	if category.ParentID == nil {
+		return nil
+	}
+	if err := tx.Categories().LockTree(ctx); err != nil {
+		return err
+	}
+	parentID := *category.ParentID
+	if parentID == category.ID {
+		return errors.InvalidField("parent_id", "a category cannot be its own parent").WithCode("category_cycle")
+	}
+	_, err := tx.Categories().GetByID(ctx, parentID)
+	if errors.KindOf(err) == errors.KindNotFound {
+		return errors.InvalidField("parent_id", "category %d does not exist", parentID)
+	}
 	if err != nil {
 		return err
 	}
-	u.invalidateCache()
+	// New categories have no descendants yet.
+	if category.ID == 0 {
+		return nil
+	}
+	ancestors, err := tx.Categories().Ancestors(ctx, parentID)
+	if err != nil {
+		return err
+	}
+	for _, ancestor := range ancestors {
+		if ancestor.ID == category.ID {
+			return errors.InvalidField("parent_id", "category %d is a subcategory of this category", parentID).WithCode("category_cycle")
+		}
+	}
 	return nil
 }
 
-func (u *categoryUsecase) GetAllCategories() ([]entity.Category, error) {
-	return u.repo.GetAll()
+// GetCategoryHistory returns a page of the audit entries of category
+// query.EntityID. It is not cached.
+func (u *categoryUsecase) GetCategoryHistory(ctx context.Context, query repository.AuditQuery) ([]entity.AuditEntry, int64, error) {
+	query.EntityType = entity.AuditCategory
+	return loadHistory(ctx, u.uow, query, categorySnapshot(entity.Category{}))
 }
 
-func (u *categoryUsecase) invalidateCache() {
-	ctx := context.Background()
-	u.cache.FlushDB(ctx)
+// invalidateCache drops the cached category and every cached product that
+// embeds it. Product lists embed categories too, so both list generations
+// are bumped.
+func (u *categoryUsecase) invalidateCache(ctx context.Context, id uint) {
+	// Invalidate even if the request is cancelled right after the commit.
+	ctx = context.WithoutCancel(ctx)
+	u.cache.Delete(ctx, categoryCacheKey(id))
+	u.cache.DeleteByTag(ctx, categoryTag(id))
+	bumpGeneration(ctx, u.cache, categoriesList, productsList)
 }
@@ -0,0 +1,233 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/reinhardjs/dot-backend-test/internal/domain/entity"
+	"github.com/reinhardjs/dot-backend-test/internal/domain/repository"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+)
+
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// ProductOperation is a single create, update or delete in a batch.
+type ProductOperation struct {
+	Op string
+	// ID identifies the product to update or delete.
+	ID uint
+	// Version makes an update or delete conditional on the product still
+	// having it; zero skips the check.
+	Version uint
+	// Product is the product to create.
+	Product entity.Product
+	// Apply changes the current product for an update and returns the names
+	// of the fields it changed.
+	Apply func(product *entity.Product) []string
+}
+
+// ProductOperationResult is the outcome of the operation at the same index.
+// Product is the created or updated product; it is nil for deletes.
+type ProductOperationResult struct {
+	Product *entity.Product
+	Err     error
+}
+
+// OperationError reports the operation that made an atomic batch fail.
+type OperationError struct {
+	Index int
+	Err   error
+}
+
+func (e *OperationError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+func (e *OperationError) Unwrap() error {
+	return e.Err
+}
+
+// BatchProducts runs ops in a single transaction. Creates are inserted first,
+// with multi-row inserts, followed by the updates and deletes in order.
+//
+// If atomic, the first failing operation rolls back the whole batch and is
+// returned as an *OperationError. Otherwise every operation runs in its own
+// savepoint, so failed operations are reported in their result and the rest
+// are committed. Either way the cache is invalidated once, after the commit.
+func (u *productUsecase) BatchProducts(ctx context.Context, ops []ProductOperation, atomic bool) ([]ProductOperationResult, error) {
+	var results []ProductOperationResult
+	err := u.uow.Do(ctx, func(tx repository.UnitOfWork) error {
+		results = make([]ProductOperationResult, len(ops))
+		if err := createProducts(ctx, tx, ops, results, atomic); err != nil {
+			return err
+		}
+		if err := modifyProducts(ctx, tx, ops, results, atomic); err != nil {
+			return err
+		}
+
+		var ids []uint
+		for i, op := range ops {
+			if results[i].Err != nil {
+				continue
+			}
+			if results[i].Product != nil {
+				ids = append(ids, results[i].Product.ID)
+			} else {
+				ids = append(ids, op.ID)
+			}
+		}
+		if len(ids) > 0 {
+			tx.AfterCommit(func() { u.invalidateCache(ctx, ids...) })
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func createProducts(ctx context.Context, tx repository.UnitOfWork, ops []ProductOperation, results []ProductOperationResult, atomic bool) error {
+	var indexes []int
+	var products []entity.Product
+	for i, op := range ops {
+		if op.Op == OpCreate {
+			indexes = append(indexes, i)
+			products = append(products, op.Product)
+		}
+	}
+	if len(products) == 0 {
+		return nil
+	}
+
+	err := tx.Do(ctx, func(tx repository.UnitOfWork) error {
+		if err := tx.Products().CreateBatch(ctx, products); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, entity.AuditProduct, entity.AuditCreate, createdProducts(products)...)
+	})
+	if err == nil {
+		for n, i := range indexes {
+			results[i].Product = &products[n]
+		}
+		return nil
+	}
+	if errors.KindOf(err) == errors.KindUnavailable {
+		return err
+	}
+
+	// Some product was rejected. Insert them one at a time to find out which.
+	for _, i := range indexes {
+		product := ops[i].Product
+		err := tx.Do(ctx, func(tx repository.UnitOfWork) error {
+			if err := tx.Products().Create(ctx, &product); err != nil {
+				return err
+			}
+			return recordAudit(ctx, tx, entity.AuditProduct, entity.AuditCreate, auditChange{id: product.ID, after: productSnapshot(product)})
+		})
+		if err != nil {
+			if atomic || errors.KindOf(err) == errors.KindUnavailable {
+				return &OperationError{Index: i, Err: err}
+			}
+			results[i].Err = err
+			continue
+		}
+		results[i].Product = &product
+	}
+	return nil
+}
+
+func modifyProducts(ctx context.Context, tx repository.UnitOfWork, ops []ProductOperation, results []ProductOperationResult, atomic bool) error {
+	var ids []uint
+	for _, op := range ops {
+		if op.Op == OpUpdate || op.Op == OpDelete {
+			ids = append(ids, op.ID)
+		}
+	}
+	found, err := tx.Products().FindByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	current := make(map[uint]entity.Product, len(found))
+	for _, product := range found {
+		current[product.ID] = product
+	}
+
+	for i, op := range ops {
+		if op.Op != OpUpdate && op.Op != OpDelete {
+			continue
+		}
+
+		result, err := modifyProduct(ctx, tx, op, current, atomic)
+		if err != nil {
+			if atomic || errors.KindOf(err) == errors.KindUnavailable {
+				return &OperationError{Index: i, Err: err}
+			}
+			results[i].Err = err
+			continue
+		}
+
+		// Later operations on the same product see this one.
+		if result != nil {
+			current[op.ID] = *result
+		} else {
+			delete(current, op.ID)
+		}
+		results[i].Product = result
+	}
+	return nil
+}
+
+// modifyProduct runs an update or delete, in a savepoint unless atomic, and
+// returns the updated product.
+func modifyProduct(ctx context.Context, tx repository.UnitOfWork, op ProductOperation, current map[uint]entity.Product, atomic bool) (*entity.Product, error) {
+	do := tx.Do
+	if atomic {
+		do = func(ctx context.Context, fn func(tx repository.UnitOfWork) error) error { return fn(tx) }
+	}
+
+	if op.Op == OpDelete {
+		return nil, do(ctx, func(tx repository.UnitOfWork) error {
+			if err := tx.Products().Delete(ctx, op.ID, op.Version); err != nil {
+				return err
+			}
+			return recordAudit(ctx, tx, entity.AuditProduct, entity.AuditDelete, auditChange{id: op.ID, before: productSnapshot(current[op.ID])})
+		})
+	}
+
+	product, ok := current[op.ID]
+	if !ok {
+		return nil, errors.NotFound("product not found")
+	}
+	if op.Version != 0 && op.Version != product.Version {
+		return nil, errors.PreconditionFailed("version %d does not match the current version %d", op.Version, product.Version)
+	}
+	before := productSnapshot(product)
+	fields := op.Apply(&product)
+	if len(fields) == 0 {
+		return &product, nil
+	}
+	err := do(ctx, func(tx repository.UnitOfWork) error {
+		if err := tx.Products().Update(ctx, &product, fields...); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, entity.AuditProduct, entity.AuditUpdate, auditChange{id: product.ID, before: before, after: productSnapshot(product)})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// createdProducts describes the creation of products for recordAudit.
+func createdProducts(products []entity.Product) []auditChange {
+	changes := make([]auditChange, len(products))
+	for i, product := range products {
+		changes[i] = auditChange{id: product.ID, after: productSnapshot(product)}
+	}
+	return changes
+}
@@ -0,0 +1,236 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/reinhardjs/dot-backend-test/internal/domain/entity"
+	"github.com/reinhardjs/dot-backend-test/internal/domain/repository"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+)
+
+// ProductImportRow is a product read from an import file. Product must carry
+// an ExternalKey; its category is given either by Product.CategoryID or by
+// Category, the name of the category.
+type ProductImportRow struct {
+	Line     int
+	Product  entity.Product
+	Category string
+}
+
+type ImportOptions struct {
+	// DryRun reports what the import would do without writing anything.
+	DryRun bool
+	// CreateCategories creates categories referenced by name that do not
+	// exist, instead of rejecting their rows.
+	CreateCategories bool
+}
+
+type ImportReport struct {
+	DryRun            bool
+	Rows              int
+	Created           int
+	Updated           int
+	Unchanged         int
+	CategoriesCreated []string
+}
+
+// ImportProducts creates or updates a product for every row, matching
+// existing products by external key, so importing the same rows twice
+// changes nothing the second time. Rows that reference unknown or ambiguous
+// categories are all reported, by line, in a single validation error, and
+// nothing is written unless every row can be imported.
+func (u *productUsecase) ImportProducts(ctx context.Context, rows []ProductImportRow, opts ImportOptions) (ImportReport, error) {
+	report := ImportReport{DryRun: opts.DryRun, Rows: len(rows)}
+	var touched []uint
+
+	err := u.uow.Do(ctx, func(tx repository.UnitOfWork) error {
+		categories, created, err := resolveCategories(ctx, tx, rows, opts)
+		if err != nil {
+			return err
+		}
+		report.CategoriesCreated = created
+
+		keys := make([]string, len(rows))
+		for i, row := range rows {
+			keys[i] = *row.Product.ExternalKey
+		}
+		found, err := tx.Products().FindByExternalKeys(ctx, keys)
+		if err != nil {
+			return err
+		}
+		existing := make(map[string]entity.Product, len(found))
+		for _, product := range found {
+			existing[*product.ExternalKey] = product
+		}
+
+		var creates []entity.Product
+		for _, row := range rows {
+			incoming := row.Product
+			if row.Category != "" && incoming.CategoryID == 0 {
+				incoming.CategoryID = categories[strings.ToLower(row.Category)]
+			}
+
+			product, ok := existing[*incoming.ExternalKey]
+			if !ok {
+				report.Created++
+				creates = append(creates, incoming)
+				continue
+			}
+
+			before := productSnapshot(product)
+			fields := applyImport(&product, incoming)
+			if len(fields) == 0 {
+				report.Unchanged++
+				continue
+			}
+			report.Updated++
+			if opts.DryRun {
+				continue
+			}
+			if err := tx.Products().Update(ctx, &product, fields...); err != nil {
+				return err
+			}
+			change := auditChange{id: product.ID, before: before, after: productSnapshot(product)}
+			if err := recordAudit(ctx, tx, entity.AuditProduct, entity.AuditUpdate, change); err != nil {
+				return err
+			}
+			touched = append(touched, product.ID)
+		}
+
+		if opts.DryRun {
+			return nil
+		}
+		if err := tx.Products().CreateBatch(ctx, creates); err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, entity.AuditProduct, entity.AuditCreate, createdProducts(creates)...); err != nil {
+			return err
+		}
+		for _, product := range creates {
+			touched = append(touched, product.ID)
+		}
+
+		tx.AfterCommit(func() {
+			u.invalidateCache(ctx, touched...)
+			if len(created) > 0 {
+				ctx := context.WithoutCancel(ctx)
+				// Also drops cached "not found" markers for the new IDs.
+				keys := make([]string, len(created))
+				for i, name := range created {
+					keys[i] = categoryCacheKey(categories[strings.ToLower(name)])
+				}
+				u.cache.Delete(ctx, keys...)
+				bumpGeneration(ctx, u.cache, categoriesList)
+			}
+		})
+		return nil
+	})
+	if err != nil {
+		return ImportReport{}, err
+	}
+	return report, nil
+}
+
+// resolveCategories maps the lower-cased category names used by rows to
+// category IDs, creating missing categories if opts allows it, and checks
+// that the category IDs used by rows exist. It returns the names of the
+// categories it created, or would create on a dry run.
+func resolveCategories(ctx context.Context, tx repository.UnitOfWork, rows []ProductImportRow, opts ImportOptions) (map[string]uint, []string, error) {
+	var ids []uint
+	var names []string
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		switch {
+		case row.Product.CategoryID != 0:
+			ids = append(ids, row.Product.CategoryID)
+		case !seen[strings.ToLower(row.Category)]:
+			seen[strings.ToLower(row.Category)] = true
+			names = append(names, row.Category)
+		}
+	}
+
+	byID, err := tx.Categories().FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	known := make(map[uint]bool, len(byID))
+	for _, category := range byID {
+		known[category.ID] = true
+	}
+
+	byName, err := tx.Categories().FindByNames(ctx, names)
+	if err != nil {
+		return nil, nil, err
+	}
+	matches := make(map[string][]uint)
+	for _, category := range byName {
+		name := strings.ToLower(category.Name)
+		matches[name] = append(matches[name], category.ID)
+	}
+
+	resolved := make(map[string]uint)
+	var missing []string
+	var fields []errors.FieldError
+	for _, row := range rows {
+		if row.Product.CategoryID != 0 {
+			if !known[row.Product.CategoryID] {
+				fields = append(fields, errors.FieldError{Row: row.Line, Field: "category_id", Message: fmt.Sprintf("category %d does not exist", row.Product.CategoryID)})
+			}
+			continue
+		}
+
+		name := strings.ToLower(row.Category)
+		switch len(matches[name]) {
+		case 0:
+			if !opts.CreateCategories {
+				fields = append(fields, errors.FieldError{Row: row.Line, Field: "category", Message: fmt.Sprintf("category %q does not exist", row.Category)})
+			} else if _, ok := resolved[name]; !ok {
+				resolved[name] = 0
+				missing = append(missing, row.Category)
+			}
+		case 1:
+			resolved[name] = matches[name][0]
+		default:
+			fields = append(fields, errors.FieldError{Row: row.Line, Field: "category", Message: fmt.Sprintf("several categories are named %q; use category_id instead", row.Category)})
+		}
+	}
+	if len(fields) > 0 {
+		return nil, nil, errors.InvalidFields(fields...)
+	}
+
+	if !opts.DryRun {
+		for _, name := range missing {
+			category := entity.Category{Name: name}
+			if err := tx.Categories().Create(ctx, &category); err != nil {
+				return nil, nil, err
+			}
+			change := auditChange{id: category.ID, after: categorySnapshot(category)}
+			if err := recordAudit(ctx, tx, entity.AuditCategory, entity.AuditCreate, change); err != nil {
+				return nil, nil, err
+			}
+			resolved[strings.ToLower(name)] = category.ID
+		}
+	}
+	return resolved, missing, nil
+}
+
+// applyImport copies the imported fields of incoming onto product and returns
+// the names of the fields that changed.
+func applyImport(product *entity.Product, incoming entity.Product) []string {
+	var fields []string
+	if incoming.Name != product.Name {
+		product.Name = incoming.Name
+		fields = append(fields, "Name")
+	}
+	if incoming.Price != product.Price {
+		product.Price = incoming.Price
+		fields = append(fields, "Price")
+	}
+	if incoming.CategoryID != product.CategoryID {
+		product.CategoryID = incoming.CategoryID
+		fields = append(fields, "CategoryID")
+	}
+	return fields
+}
@@ -0,0 +1,60 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/reinhardjs/dot-backend-test/internal/domain/entity"
+	"github.com/reinhardjs/dot-backend-test/internal/domain/repository"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
+)
+
+// GetDeletedProducts lists the products in the trash. They are never cached.
+func (u *productUsecase) GetDeletedProducts(ctx context.Context, page, pageSize int) ([]entity.Product, int64, error) {
+	return u.uow.Products().FindDeleted(ctx, page, pageSize)
+}
+
+// RestoreProduct takes a product out of the trash. Its category has to be
+// restored first.
+func (u *productUsecase) RestoreProduct(ctx context.Context, id uint) (*entity.Product, error) {
+	var product *entity.Product
+	err := u.uow.Do(ctx, func(tx repository.UnitOfWork) error {
+		deleted, err := tx.Products().FindDeletedByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		// Keeps the category from being deleted until the product is back.
+		categories, err := tx.Categories().Lock(ctx, deleted.CategoryID)
+		if err != nil {
+			return err
+		}
+		if len(categories) == 0 {
+			return errors.Conflict("category %d of the product is deleted, restore it first", deleted.CategoryID).WithCode("category_deleted")
+		}
+
+		if err := tx.Products().Restore(ctx, id); err != nil {
+			return err
+		}
+		if product, err = tx.Products().FindByID(ctx, id); err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, entity.AuditProduct, entity.AuditRestore, auditChange{id: id, after: productSnapshot(*product)}); err != nil {
+			return err
+		}
+		// Also drops the cached "not found" marker.
+		tx.AfterCommit(func() { u.invalidateCache(ctx, id) })
+		return nil
+	})
+	return product, err
+}
+
+// PurgeProduct permanently deletes a product in the trash.
+func (u *productUsecase) PurgeProduct(ctx context.Context, id uint) error {
+	return u.uow.Products().Purge(ctx, id)
+}
+
+// PurgeDeletedProducts permanently deletes the products that were moved to
+// the trash before the given time.
+func (u *productUsecase) PurgeDeletedProducts(ctx context.Context, before time.Time) (int64, error) {
+	return u.uow.Products().PurgeDeletedBefore(ctx, before)
+}
@@ -3,107 +3,204 @@ package usecase
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"time"
 
 	"github.com/reinhardjs/dot-backend-test/internal/domain/entity"
 	"github.com/reinhardjs/dot-backend-test/internal/domain/repository"
 	"github.com/reinhardjs/dot-backend-test/internal/infrastructure/cache"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
 	"gorm.io/gorm"
 )
 
 type ProductUsecase interface {
-	CreateProduct(product *entity.Product) error
-	GetProductByID(id uint) (*entity.Product, error)
-	UpdateProduct(product *entity.Product) error
-	DeleteProduct(id uint) error
-	GetAllProducts() ([]entity.Product, error)
+	CreateProduct(ctx context.Context, product *entity.Product) error
+	GetProductByID(ctx context.Context, id uint) (*entity.Product, error)
+	GetProductForUpdate(ctx context.Context, id uint) (*entity.Product, error)
+	UpdateProduct(ctx context.Context, product *entity.Product, fields ...string) error
+	DeleteProduct(ctx context.Context, id uint, version uint) error
+	GetAllProducts(ctx context.Context, query repository.ProductQuery) ([]entity.Product, int64, error)
+	GetProductsAfter(ctx context.Context, query repository.ProductQuery, after *repository.Keyset) ([]entity.Product, error)
+	GetProductStats(ctx context.Context, query repository.ProductQuery, includeCategories bool) (repository.ListStats, error)
+	BatchProducts(ctx context.Context, ops []ProductOperation, atomic bool) ([]ProductOperationResult, error)
+	ImportProducts(ctx context.Context, rows []ProductImportRow, opts ImportOptions) (ImportReport, error)
+	ExportProducts(ctx context.Context, query repository.ProductQuery, fn func(product entity.Product) error) error
+	GetDeletedProducts(ctx context.Context, page, pageSize int) ([]entity.Product, int64, error)
+	RestoreProduct(ctx context.Context, id uint) (*entity.Product, error)
+	PurgeProduct(ctx context.Context, id uint) error
+	PurgeDeletedProducts(ctx context.Context, before time.Time) (int64, error)
+	GetProductHistory(ctx context.Context, query repository.AuditQuery) ([]entity.AuditEntry, int64, error)
 }
 
 type productUsecase struct {
-	repo  repository.ProductRepository
-	cache *cache.RedisClient
-	db    *gorm.DB
+	uow    repository.UnitOfWork
+	cache  cache.Cache
+	loader *cache.Loader
 }
 
-func NewProductUsecase(db *gorm.DB, cache *cache.RedisClient) ProductUsecase {
+func NewProductUsecase(db *gorm.DB, cache cache.Cache, loader *cache.Loader) ProductUsecase {
 	return &productUsecase{
-		repo:  repository.NewProductRepository(db),
-		cache: cache,
-		db:    db,
+		uow:    repository.NewUnitOfWork(db),
+		cache:  cache,
+		loader: loader,
 	}
 }
 
-func (u *productUsecase) CreateProduct(product *entity.Product) error {
-	err := u.db.Transaction(func(tx *gorm.DB) error {
-		if err := u.repo.Create(product); err != nil {
+func (u *productUsecase) CreateProduct(ctx context.Context, product *entity.Product) error {
+	return u.uow.Do(ctx, func(tx repository.UnitOfWork) error {
+		if err := tx.Products().Create(ctx, product); err != nil {
 			return err
 		}
+		if err := recordAudit(ctx, tx, entity.AuditProduct, entity.AuditCreate, auditChange{id: product.ID, after: productSnapshot(*product)}); err != nil {
+			return err
+		}
+		// Also drops a cached "not found" marker for the new ID.
+		tx.AfterCommit(func() { u.invalidateCache(ctx, product.ID) })
 		return nil
 	})
-	if err != nil {
-		return err
-	}
-	u.invalidateCache()
-	return nil
 }
 
-func (u *productUsecase) GetProductByID(id uint) (*entity.Product, error) {
-	ctx := context.Background()
-	cacheKey := fmt.Sprintf("product:%d", id)
-
-	cachedProduct, err := u.cache.Client.Get(ctx, cacheKey).Result()
-	if err == nil {
-		var product entity.Product
-		if err := json.Unmarshal([]byte(cachedProduct), &product); err == nil {
-			return &product, nil
+func (u *productUsecase) GetProductByID(ctx context.Context, id uint) (*entity.Product, error) {
+	cachedProduct, err := u.loader.Load(ctx, "products.get", productCacheKey(id), func(ctx context.Context) ([]byte, []string, error) {
+		product, err := u.uow.Products().FindByID(ctx, id)
+		if errors.KindOf(err) == errors.KindNotFound {
+			return nil, nil, nil
 		}
-	}
-
-	product, err := u.repo.FindByID(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		productJSON, err := json.Marshal(product)
+		return productJSON, []string{categoryTag(product.CategoryID)}, err
+	})
 	if err != nil {
 		return nil, err
 	}
+	if cachedProduct == nil {
+		return nil, errors.NotFound("product not found")
+	}
 
-	productJSON, _ := json.Marshal(product)
-	u.cache.Set(ctx, cacheKey, productJSON, time.Minute*5)
+	var product entity.Product
+	if err := json.Unmarshal(cachedProduct, &product); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
 
-	return product, nil
+// GetProductForUpdate reads the product from the database, bypassing the
+// cache, so conditional writes compare against its current version.
+func (u *productUsecase) GetProductForUpdate(ctx context.Context, id uint) (*entity.Product, error) {
+	return u.uow.Products().FindByID(ctx, id)
 }
 
-func (u *productUsecase) UpdateProduct(product *entity.Product) error {
-	err := u.db.Transaction(func(tx *gorm.DB) error {
-		if err := u.repo.Update(product); err != nil {
+// UpdateProduct writes the given fields of product. It does nothing when no fields
+// are given.
+func (u *productUsecase) UpdateProduct(ctx context.Context, product *entity.Product, fields ...string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return u.uow.Do(ctx, func(tx repository.UnitOfWork) error {
+		before, err := tx.Products().FindByID(ctx, product.ID)
+		if err != nil {
 			return err
 		}
+		if err := tx.Products().Update(ctx, product, fields...); err != nil {
+			return err
+		}
+		change := auditChange{id: product.ID, before: productSnapshot(*before), after: productSnapshot(*product)}
+		if err := recordAudit(ctx, tx, entity.AuditProduct, entity.AuditUpdate, change); err != nil {
+			return err
+		}
+		tx.AfterCommit(func() { u.invalidateCache(ctx, product.ID) })
 		return nil
 	})
-	if err != nil {
-		return err
-	}
-	u.invalidateCache()
-	return nil
 }
 
-func (u *productUsecase) DeleteProduct(id uint) error {
-	err := u.db.Transaction(func(tx *gorm.DB) error {
-		if err := u.repo.Delete(id); err != nil {
+func (u *productUsecase) DeleteProduct(ctx context.Context, id uint, version uint) error {
+	return u.uow.Do(ctx, func(tx repository.UnitOfWork) error {
+		before, err := tx.Products().FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Products().Delete(ctx, id, version); err != nil {
 			return err
 		}
+		if err := recordAudit(ctx, tx, entity.AuditProduct, entity.AuditDelete, auditChange{id: id, before: productSnapshot(*before)}); err != nil {
+			return err
+		}
+		tx.AfterCommit(func() { u.invalidateCache(ctx, id) })
 		return nil
 	})
-	if err != nil {
-		return err
-	}
-	u.invalidateCache()
-	return nil
 }
 
-func (u *productUsecase) GetAllProducts() ([]entity.Product, error) {
-	return u.repo.FindAll()
+type productPage struct {
+	Products []entity.Product `json:"products"`
+	Total    int64            `json:"total"`
+}
+
+func (u *productUsecase) GetAllProducts(ctx context.Context, query repository.ProductQuery) ([]entity.Product, int64, error) {
+	query.Page, query.PageSize = repository.NormalizePage(query.Page, query.PageSize)
+
+	page, err := loadList(ctx, u.cache, u.loader, "products.list", productsList, query, func(ctx context.Context) (productPage, error) {
+		products, total, err := u.uow.Products().FindByQuery(ctx, query)
+		return productPage{Products: products, Total: total}, err
+	})
+	return page.Products, page.Total, err
+}
+
+func (u *productUsecase) GetProductsAfter(ctx context.Context, query repository.ProductQuery, after *repository.Keyset) ([]entity.Product, error) {
+	key := struct {
+		Query repository.ProductQuery
+		After *repository.Keyset
+	}{query, after}
+
+	return loadList(ctx, u.cache, u.loader, "products.list", productsList, key, func(ctx context.Context) ([]entity.Product, error) {
+		return u.uow.Products().FindByKeyset(ctx, query, after)
+	})
 }
 
-func (u *productUsecase) invalidateCache() {
-	ctx := context.Background()
-	u.cache.FlushDB(ctx)
+// GetProductStats summarizes the products matching the filters of query. With
+// includeCategories, changes to categories count as modifications too, for
+// listings that embed them. It is cached alongside the list, so it changes
+// exactly when the list does.
+func (u *productUsecase) GetProductStats(ctx context.Context, query repository.ProductQuery, includeCategories bool) (repository.ListStats, error) {
+	query.Page, query.PageSize, query.Sort = 0, 0, nil
+	key := struct {
+		Stats      repository.ProductQuery
+		Categories bool
+	}{query, includeCategories}
+
+	return loadList(ctx, u.cache, u.loader, "products.stats", productsList, key, func(ctx context.Context) (repository.ListStats, error) {
+		stats, err := u.uow.Products().Stats(ctx, query)
+		if err != nil || !includeCategories {
+			return stats, err
+		}
+		categoryStats, err := u.uow.Categories().Stats(ctx)
+		if categoryStats.LastModified.After(stats.LastModified) {
+			stats.LastModified = categoryStats.LastModified
+		}
+		return stats, err
+	})
+}
+
+// ExportProducts streams every product matching the filters of query to fn,
+// bypassing the cache, which only holds single pages.
+func (u *productUsecase) ExportProducts(ctx context.Context, query repository.ProductQuery, fn func(product entity.Product) error) error {
+	return u.uow.Products().Each(ctx, query, fn)
+}
+
+// GetProductHistory returns a page of the audit entries of product
+// query.EntityID. It is not cached.
+func (u *productUsecase) GetProductHistory(ctx context.Context, query repository.AuditQuery) ([]entity.AuditEntry, int64, error) {
+	query.EntityType = entity.AuditProduct
+	return loadHistory(ctx, u.uow, query, productSnapshot(entity.Product{}))
+}
+
+func (u *productUsecase) invalidateCache(ctx context.Context, ids ...uint) {
+	// Invalidate even if the request is cancelled right after the commit.
+	ctx = context.WithoutCancel(ctx)
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = productCacheKey(id)
+	}
+	u.cache.Delete(ctx, keys...)
+	bumpGeneration(ctx, u.cache, productsList)
 }
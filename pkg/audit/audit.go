@@ -0,0 +1,63 @@
+// Package audit carries who made a request through its context and computes
+// the field changes that audit entries record.
+package audit
+
+import (
+	"context"
+	"reflect"
+)
+
+// Anonymous is the actor of requests that do not name one.
+const Anonymous = "anonymous"
+
+type contextKey int
+
+const (
+	actorKey contextKey = iota
+	requestIDKey
+)
+
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// Actor returns the actor attached to ctx, or Anonymous.
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey).(string); ok && actor != "" {
+		return actor
+	}
+	return Anonymous
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the request ID attached to ctx, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// Change is the value of a field before and after a write.
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Diff returns the fields whose values differ between the snapshots before
+// and after. before is nil for creates and after is nil for deletes.
+func Diff(before, after map[string]interface{}) map[string]Change {
+	changes := make(map[string]Change)
+	for field, value := range after {
+		if !reflect.DeepEqual(before[field], value) {
+			changes[field] = Change{Before: before[field], After: value}
+		}
+	}
+	for field, value := range before {
+		if _, ok := after[field]; !ok && value != nil {
+			changes[field] = Change{Before: value}
+		}
+	}
+	return changes
+}
@@ -0,0 +1,34 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	before := map[string]interface{}{"name": "Phone", "price": 9.5, "external_key": nil}
+	after := map[string]interface{}{"name": "Phone", "price": 12.0, "external_key": nil}
+	assert.Equal(t, map[string]Change{"price": {Before: 9.5, After: 12.0}}, Diff(before, after))
+
+	assert.Equal(t, map[string]Change{
+		"name":  {After: "Phone"},
+		"price": {After: 9.5},
+	}, Diff(nil, before))
+	assert.Equal(t, map[string]Change{
+		"name":  {Before: "Phone"},
+		"price": {Before: 9.5},
+	}, Diff(before, nil))
+	assert.Empty(t, Diff(before, before))
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, Anonymous, Actor(ctx))
+	assert.Empty(t, RequestID(ctx))
+
+	ctx = WithRequestID(WithActor(ctx, "alice"), "req-1")
+	assert.Equal(t, "alice", Actor(ctx))
+	assert.Equal(t, "req-1", RequestID(ctx))
+}
@@ -0,0 +1,54 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodec(t *testing.T) {
+	codec := NewCodec("secret")
+	cur := Cursor{Sort: "price", Desc: true, Filter: "abc", Value: json.RawMessage(`9.5`), ID: 42}
+
+	token, err := codec.Encode(cur)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	t.Run("Round Trip", func(t *testing.T) {
+		decoded, err := codec.Decode(token)
+		assert.NoError(t, err)
+		assert.Equal(t, cur, decoded)
+	})
+
+	t.Run("Rejects Modified Payload", func(t *testing.T) {
+		_, sig, _ := strings.Cut(token, ".")
+		payload := base64.RawURLEncoding.EncodeToString([]byte(`{"s":"price","d":true,"f":"abc","v":9.5,"i":1}`))
+		_, err := codec.Decode(payload + "." + sig)
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+
+	t.Run("Rejects Modified Signature", func(t *testing.T) {
+		payload, sig, _ := strings.Cut(token, ".")
+		raw, _ := base64.RawURLEncoding.DecodeString(sig)
+		raw[0] ^= 0xff
+		_, err := codec.Decode(payload + "." + base64.RawURLEncoding.EncodeToString(raw))
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+
+	t.Run("Rejects Other Secret", func(t *testing.T) {
+		_, err := NewCodec("other").Decode(token)
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+	})
+
+	t.Run("Rejects Malformed Tokens", func(t *testing.T) {
+		payload, sig, _ := strings.Cut(token, ".")
+		for _, malformed := range []string{"", payload, "!!!." + sig, payload + ".!!!"} {
+			_, err := codec.Decode(malformed)
+			assert.ErrorIs(t, err, ErrInvalidCursor, malformed)
+		}
+	})
+}
@@ -1,18 +1,266 @@
 package errors
 
 import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindNotFound
+	KindConflict
+	KindValidation
+	KindUnavailable
+	KindUnsupportedMediaType
+	KindPreconditionFailed
+	KindPreconditionRequired
+	KindUnprocessable
+	KindPayloadTooLarge
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindNotFound:
+		return "not_found"
+	case KindConflict:
+		return "conflict"
+	case KindValidation:
+		return "validation"
+	case KindUnavailable:
+		return "unavailable"
+	case KindUnsupportedMediaType:
+		return "unsupported_media_type"
+	case KindPreconditionFailed:
+		return "precondition_failed"
+	case KindPreconditionRequired:
+		return "precondition_required"
+	case KindUnprocessable:
+		return "unprocessable"
+	case KindPayloadTooLarge:
+		return "payload_too_large"
+	}
+	return "internal"
+}
+
+// Status is the HTTP status code errors of kind k are rendered with.
+func (k Kind) Status() int {
+	switch k {
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindValidation:
+		return http.StatusBadRequest
+	case KindUnavailable:
+		return http.StatusServiceUnavailable
+	case KindUnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	case KindPreconditionFailed:
+		return http.StatusPreconditionFailed
+	case KindPreconditionRequired:
+		return http.StatusPreconditionRequired
+	case KindUnprocessable:
+		return http.StatusUnprocessableEntity
+	case KindPayloadTooLarge:
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusInternalServerError
+}
+
+// Error is a domain error. Message is safe to show to clients; Err is the
+// underlying cause and is only logged. Code is a machine-readable identifier
+// that defaults to the kind, and Fields lists per-field validation failures.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+// FieldError describes why a single request field was rejected.
+type FieldError struct {
+	// Row is the line of an uploaded file the field is on, if any.
+	Row     int    `json:"row,omitempty"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithCode overrides the machine-readable code reported to clients.
+func (e *Error) WithCode(code string) *Error {
+	e.Code = code
+	return e
+}
+
+func NotFound(format string, args ...interface{}) *Error {
+	return &Error{Kind: KindNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+func Conflict(format string, args ...interface{}) *Error {
+	return &Error{Kind: KindConflict, Message: fmt.Sprintf(format, args...)}
+}
+
+func Validation(format string, args ...interface{}) *Error {
+	return &Error{Kind: KindValidation, Message: fmt.Sprintf(format, args...)}
+}
+
+// InvalidField reports a validation failure of a single request field.
+func InvalidField(field, format string, args ...interface{}) *Error {
+	return InvalidFields(FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// InvalidFields reports validation failures of one or more request fields.
+func InvalidFields(fields ...FieldError) *Error {
+	message := "request contains invalid fields"
+	if len(fields) == 1 {
+		message = fields[0].Message
+	}
+	return &Error{Kind: KindValidation, Message: message, Fields: fields}
+}
+
+func UnsupportedMediaType(format string, args ...interface{}) *Error {
+	return &Error{Kind: KindUnsupportedMediaType, Message: fmt.Sprintf(format, args...)}
+}
+
+func PreconditionFailed(format string, args ...interface{}) *Error {
+	return &Error{Kind: KindPreconditionFailed, Message: fmt.Sprintf(format, args...)}
+}
+
+func PreconditionRequired(format string, args ...interface{}) *Error {
+	return &Error{Kind: KindPreconditionRequired, Message: fmt.Sprintf(format, args...)}
+}
+
+func Unprocessable(format string, args ...interface{}) *Error {
+	return &Error{Kind: KindUnprocessable, Message: fmt.Sprintf(format, args...)}
+}
+
+func PayloadTooLarge(format string, args ...interface{}) *Error {
+	return &Error{Kind: KindPayloadTooLarge, Message: fmt.Sprintf(format, args...)}
+}
+
+func Unavailable(err error, format string, args ...interface{}) *Error {
+	return &Error{Kind: KindUnavailable, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+func Internal(err error) *Error {
+	return &Error{Kind: KindInternal, Message: "internal server error", Err: err}
+}
+
+// Wrap attaches err as the cause of a domain error of the given kind.
+func Wrap(kind Kind, err error, format string, args ...interface{}) *Error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+// KindOf returns the kind of the first domain error in err's chain. Expired
+// or cancelled contexts count as unavailable; anything else is internal.
+func KindOf(err error) Kind {
+	var domainErr *Error
+	if stderrors.As(err, &domainErr) {
+		return domainErr.Kind
+	}
+	if stderrors.Is(err, context.DeadlineExceeded) || stderrors.Is(err, context.Canceled) {
+		return KindUnavailable
+	}
+	return KindInternal
+}
+
+// Is and As mirror the standard library so that this package can be
+// imported in place of it.
+func Is(err, target error) bool {
+	return stderrors.Is(err, target)
+}
+
+func As(err error, target interface{}) bool {
+	return stderrors.As(err, target)
+}
+
+const (
+	ProblemContentType = "application/problem+json"
+	legacyContentType  = "application/json"
+)
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail"`
+	Instance string       `json:"instance"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// ErrorHandler renders the last error attached to the context with c.Error,
+// choosing the status code from its Kind. Only domain error messages reach
+// the client; anything else is logged and reported as an internal error.
+//
+// Errors are rendered as application/problem+json, unless the client
+// explicitly prefers application/json, in which case the legacy
+// {"error": "..."} shape is used.
 func ErrorHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Next()
 
-		if len(c.Errors) > 0 {
-			err := c.Errors.Last()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		problem := NewProblem(err, c.Request.URL.Path)
+
+		if problem.Status >= http.StatusInternalServerError {
+			log.Printf("%s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+		}
+
+		if c.NegotiateFormat(ProblemContentType, legacyContentType) == legacyContentType {
+			c.JSON(problem.Status, gin.H{"error": problem.Detail})
+			return
+		}
+		c.Header("Content-Type", ProblemContentType)
+		c.JSON(problem.Status, problem)
+	}
+}
+
+// NewProblem describes err as problem details for the given request path.
+func NewProblem(err error, instance string) Problem {
+	kind := KindOf(err)
+	problem := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(kind.Status()),
+		Status:   kind.Status(),
+		Detail:   "internal server error",
+		Instance: instance,
+		Code:     kind.String(),
+	}
+
+	var domainErr *Error
+	switch {
+	case stderrors.As(err, &domainErr) && domainErr.Kind != KindInternal:
+		problem.Detail = domainErr.Message
+		problem.Errors = domainErr.Fields
+		if domainErr.Code != "" {
+			problem.Code = domainErr.Code
 		}
+	case kind == KindUnavailable:
+		problem.Detail = "request timed out or was cancelled"
 	}
+	return problem
 }
@@ -0,0 +1,74 @@
+package errors
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func serveError(err error, accept string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(ErrorHandler())
+	router.GET("/products/:id", func(c *gin.Context) {
+		c.Error(err)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/products/7", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestErrorHandler(t *testing.T) {
+	// Problem details by default
+	w := serveError(NotFound("product not found"), "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+
+	var problem Problem
+	json.Unmarshal(w.Body.Bytes(), &problem)
+	assert.Equal(t, Problem{
+		Type:     "about:blank",
+		Title:    "Not Found",
+		Status:   http.StatusNotFound,
+		Detail:   "product not found",
+		Instance: "/products/7",
+		Code:     "not_found",
+	}, problem)
+
+	// Legacy shape for clients that explicitly ask for application/json
+	w = serveError(NotFound("product not found"), "application/json")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error": "product not found"}`, w.Body.String())
+
+	// Field violations and custom codes
+	w = serveError(InvalidFields(
+		FieldError{Field: "name", Message: "name cannot be empty"},
+		FieldError{Field: "price", Message: "price must be non-negative"},
+	).WithCode("invalid_product"), "application/problem+json")
+	problem = Problem{}
+	json.Unmarshal(w.Body.Bytes(), &problem)
+	assert.Equal(t, http.StatusBadRequest, problem.Status)
+	assert.Equal(t, "invalid_product", problem.Code)
+	assert.Len(t, problem.Errors, 2)
+	assert.Equal(t, "price", problem.Errors[1].Field)
+
+	// Causes of internal errors are not exposed
+	w = serveError(stderrors.New("pq: password authentication failed"), "")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "password")
+
+	// Expired contexts are reported as unavailable
+	w = serveError(context.DeadlineExceeded, "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
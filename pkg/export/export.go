@@ -0,0 +1,175 @@
+// Package export writes tables row by row as CSV, NDJSON or XLSX, so that
+// exports can be streamed without holding all rows in memory.
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	CSV    = "csv"
+	NDJSON = "ndjson"
+	XLSX   = "xlsx"
+)
+
+// Writer writes the rows of a table. Values may be strings, integers, floats,
+// time.Time or nil; pointers to those are dereferenced. Close must be called
+// to complete the output.
+type Writer interface {
+	Write(values ...interface{}) error
+	Close() error
+}
+
+// NewWriter returns a Writer for format that writes a table with the given
+// columns to w.
+func NewWriter(w io.Writer, format string, columns []string) (Writer, error) {
+	switch format {
+	case CSV:
+		return newCSVWriter(w, columns)
+	case NDJSON:
+		return &ndjsonWriter{w: bufio.NewWriter(w), columns: columns}, nil
+	case XLSX:
+		return newXLSXWriter(w, columns)
+	}
+	return nil, fmt.Errorf("unsupported export format %q", format)
+}
+
+// ContentType is the media type of files of the given format.
+func ContentType(format string) string {
+	switch format {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	case NDJSON:
+		return "application/x-ndjson"
+	case XLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "application/octet-stream"
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func newCSVWriter(w io.Writer, columns []string) (*csvWriter, error) {
+	writer := &csvWriter{w: csv.NewWriter(w), record: make([]string, len(columns))}
+	return writer, writer.w.Write(columns)
+}
+
+func (w *csvWriter) Write(values ...interface{}) error {
+	for i, value := range values {
+		w.record[i] = formatValue(value)
+		if _, ok := deref(value).(string); ok {
+			w.record[i] = escapeFormula(w.record[i])
+		}
+	}
+	return w.w.Write(w.record)
+}
+
+// formulaPrefixes are the characters that make spreadsheet applications
+// evaluate a CSV cell as a formula.
+const formulaPrefixes = "=+-@\t\r"
+
+// escapeFormula prefixes text cells that would be evaluated as a formula with
+// a single quote, so spreadsheets show them as text instead. Numbers are never
+// escaped, as negative values would otherwise turn into text.
+func escapeFormula(cell string) string {
+	if cell != "" && strings.ContainsRune(formulaPrefixes, rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// UnescapeFormula reverses the escaping of a text cell read back from a CSV
+// export.
+func UnescapeFormula(cell string) string {
+	if len(cell) > 1 && cell[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(cell[1])) {
+		return cell[1:]
+	}
+	return cell
+}
+
+func (w *csvWriter) Close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type ndjsonWriter struct {
+	w       *bufio.Writer
+	columns []string
+}
+
+func (w *ndjsonWriter) Write(values ...interface{}) error {
+	w.w.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			w.w.WriteByte(',')
+		}
+		key, _ := json.Marshal(w.columns[i])
+		w.w.Write(key)
+		w.w.WriteByte(':')
+
+		data, err := json.Marshal(deref(value))
+		if err != nil {
+			return err
+		}
+		w.w.Write(data)
+	}
+	w.w.WriteString("}\n")
+	// bufio.Writer keeps the first write error and returns it from here on.
+	_, err := w.w.Write(nil)
+	return err
+}
+
+func (w *ndjsonWriter) Close() error {
+	return w.w.Flush()
+}
+
+// deref returns the value a pointer points to, or nil for a nil pointer.
+func deref(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *string:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *float64:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *uint:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return *v
+	}
+	return value
+}
+
+func formatValue(value interface{}) string {
+	switch v := deref(value).(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprint(deref(value))
+}
@@ -0,0 +1,114 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTable(t *testing.T, format string) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, format, []string{"id", "name", "price", "key", "created_at"})
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	created := time.Date(2024, 3, 14, 12, 0, 0, 0, time.UTC)
+	key := "SKU-1"
+	assert.NoError(t, w.Write(uint(1), "Desk, \"oak\"", 120.5, &key, created))
+	assert.NoError(t, w.Write(uint(2), "Lamp", 0.0, (*string)(nil), created))
+	assert.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestCSV(t *testing.T) {
+	assert.Equal(t, "id,name,price,key,created_at\n"+
+		"1,\"Desk, \"\"oak\"\"\",120.5,SKU-1,2024-03-14T12:00:00Z\n"+
+		"2,Lamp,0,,2024-03-14T12:00:00Z\n", string(writeTable(t, CSV)))
+}
+
+func TestCSVEscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, CSV, []string{"name", "key", "price"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	key := "@SUM(A1)"
+	assert.NoError(t, w.Write("=HYPERLINK(\"http://example.com\")", &key, -1.5))
+	assert.NoError(t, w.Write("-10", "+1", 2.0))
+	assert.NoError(t, w.Write("Desk", "a=b", 3.0))
+	assert.NoError(t, w.Close())
+
+	assert.Equal(t, "name,key,price\n"+
+		"\"'=HYPERLINK(\"\"http://example.com\"\")\",'@SUM(A1),-1.5\n"+
+		"'-10,'+1,2\n"+
+		"Desk,a=b,3\n", buf.String())
+
+	assert.Equal(t, "=1+2", UnescapeFormula("'=1+2"))
+	assert.Equal(t, "'quoted'", UnescapeFormula("'quoted'"))
+	assert.Equal(t, "plain", UnescapeFormula("plain"))
+}
+
+func TestNDJSON(t *testing.T) {
+	assert.Equal(t, `{"id":1,"name":"Desk, \"oak\"","price":120.5,"key":"SKU-1","created_at":"2024-03-14T12:00:00Z"}`+"\n"+
+		`{"id":2,"name":"Lamp","price":0,"key":null,"created_at":"2024-03-14T12:00:00Z"}`+"\n", string(writeTable(t, NDJSON)))
+}
+
+func TestXLSX(t *testing.T) {
+	data := writeTable(t, XLSX)
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	parts := make(map[string][]byte)
+	for _, f := range archive.File {
+		r, err := f.Open()
+		assert.NoError(t, err)
+		parts[f.Name], _ = io.ReadAll(r)
+		r.Close()
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels"} {
+		assert.Contains(t, parts, name)
+	}
+
+	var sheet struct {
+		Rows []struct {
+			R     int `xml:"r,attr"`
+			Cells []struct {
+				R      string `xml:"r,attr"`
+				T      string `xml:"t,attr"`
+				Value  string `xml:"v"`
+				Inline string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if !assert.NoError(t, xml.Unmarshal(parts["xl/worksheets/sheet1.xml"], &sheet)) {
+		return
+	}
+	assert.Len(t, sheet.Rows, 3)
+	assert.Equal(t, "created_at", sheet.Rows[0].Cells[4].Inline)
+
+	cells := sheet.Rows[1].Cells
+	assert.Equal(t, "A2", cells[0].R)
+	assert.Equal(t, "1", cells[0].Value)
+	assert.Equal(t, "inlineStr", cells[1].T)
+	assert.Equal(t, `Desk, "oak"`, cells[1].Inline)
+	assert.Equal(t, "120.5", cells[2].Value)
+	assert.Equal(t, "2024-03-14T12:00:00Z", cells[4].Inline)
+
+	// Nil values leave their cell out
+	assert.Len(t, sheet.Rows[2].Cells, 4)
+}
+
+func TestColumnName(t *testing.T) {
+	assert.Equal(t, "A", columnName(0))
+	assert.Equal(t, "Z", columnName(25))
+	assert.Equal(t, "AA", columnName(26))
+	assert.Equal(t, "AZ", columnName(51))
+	assert.Equal(t, "BA", columnName(52))
+}
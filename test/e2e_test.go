@@ -1,50 +1,99 @@
 package test
 
 import (
+	"archive/zip"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/reinhardjs/dot-backend-test/config"
 	delivery_http "github.com/reinhardjs/dot-backend-test/internal/delivery/http"
+	"github.com/reinhardjs/dot-backend-test/internal/delivery/http/dto"
 	"github.com/reinhardjs/dot-backend-test/internal/domain/entity"
 	"github.com/reinhardjs/dot-backend-test/internal/infrastructure/cache"
 	"github.com/reinhardjs/dot-backend-test/internal/infrastructure/database"
 	"github.com/reinhardjs/dot-backend-test/internal/usecase"
+	"github.com/reinhardjs/dot-backend-test/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
+func productRequest(name string, price float64, categoryID uint) dto.ProductRequest {
+    return dto.ProductRequest{Name: name, Price: &price, CategoryID: categoryID}
+}
+
+func do(router http.Handler, method, path string, body interface{}) *httptest.ResponseRecorder {
+    reader := bytes.NewBuffer(nil)
+    if body != nil {
+        data, _ := json.Marshal(body)
+        reader = bytes.NewBuffer(data)
+    }
+    w := httptest.NewRecorder()
+    req, _ := http.NewRequest(method, path, reader)
+    router.ServeHTTP(w, req)
+    return w
+}
+
+func createCategory(t *testing.T, router http.Handler, name string, parentID *uint) dto.CategoryResponse {
+    w := do(router, "POST", "/api/v1/categories", dto.CategoryRequest{Name: name, ParentID: parentID})
+    assert.Equal(t, http.StatusCreated, w.Code)
+    var category dto.CategoryResponse
+    json.Unmarshal(w.Body.Bytes(), &category)
+    return category
+}
+
+func createProduct(t *testing.T, router http.Handler, name string, categoryID uint) dto.ProductResponse {
+    w := do(router, "POST", "/api/v1/products", productRequest(name, 10, categoryID))
+    assert.Equal(t, http.StatusCreated, w.Code)
+    var product dto.ProductResponse
+    json.Unmarshal(w.Body.Bytes(), &product)
+    return product
+}
+
 func setupTestEnvironment(t *testing.T) *gin.Engine {
     cfg := config.Load()
+    // The tests act as the trusted gateway that names the actor.
+    cfg.TrustActorHeader = true
 
     // Connect to test database
     db, err := database.NewPostgresDB(cfg.DatabaseURL)
     assert.NoError(t, err)
 
-    // Connect to test Redis
-    cache, err := cache.NewRedisClient(cfg.RedisURL)
+    // Connect to the configured test cache
+    appCache, err := cache.New(cfg)
     assert.NoError(t, err)
 
     // Run migrations
-    err = db.AutoMigrate(&entity.Category{}, &entity.Product{})
+    err = db.AutoMigrate(&entity.Category{}, &entity.Product{}, &entity.AuditEntry{})
     assert.NoError(t, err)
 
     // Clean up database
     db.Exec("DELETE FROM products")
     db.Exec("DELETE FROM categories")
+    db.Exec("DELETE FROM audit_entries")
+
+    // Clean up the test Redis so cached entries from earlier runs do not leak in
+    if cfg.CacheDriver == cache.DriverRedis {
+        if redisClient, err := cache.NewRedisClient(cfg.RedisURL); err == nil {
+            redisClient.Client.FlushDB(context.Background())
+        }
+    }
 
     // Initialize usecases with real implementations
-    productUsecase := usecase.NewProductUsecase(db, cache)
-    categoryUsecase := usecase.NewCategoryUsecase(db, cache.Client)
+    loader := cache.NewLoaderFromConfig(appCache, cfg)
+    productUsecase := usecase.NewProductUsecase(db, appCache, loader)
+    categoryUsecase := usecase.NewCategoryUsecase(db, appCache, loader)
 
     // Set gin mode to testing for testing
     gin.SetMode(gin.TestMode)
 
-    return delivery_http.NewRouter(productUsecase, categoryUsecase)
+    return delivery_http.NewRouter(cfg, appCache, productUsecase, categoryUsecase)
 }
 
 func TestProductE2E(t *testing.T) {
@@ -52,26 +101,26 @@ func TestProductE2E(t *testing.T) {
 
     t.Run("Product CRUD Operations", func(t *testing.T) {
         // Create Category first to avoid foreign key constraint violation
-        category := entity.Category{Name: "Test Category"}
+        category := dto.CategoryRequest{Name: "Test Category"}
         body, _ := json.Marshal(category)
         w := httptest.NewRecorder()
         req, _ := http.NewRequest("POST", "/api/v1/categories", bytes.NewBuffer(body))
         router.ServeHTTP(w, req)
         assert.Equal(t, http.StatusCreated, w.Code)
 
-        var createdCategory entity.Category
+        var createdCategory dto.CategoryResponse
         json.Unmarshal(w.Body.Bytes(), &createdCategory)
         assert.NotZero(t, createdCategory.ID)
 
         // Create Product - Success
-        product := entity.Product{Name: "Test Product", Price: 9.99, CategoryID: createdCategory.ID}
+        product := productRequest("Test Product", 9.99, createdCategory.ID)
         body, _ = json.Marshal(product)
         w = httptest.NewRecorder()
         req, _ = http.NewRequest("POST", "/api/v1/products", bytes.NewBuffer(body))
         router.ServeHTTP(w, req)
         assert.Equal(t, http.StatusCreated, w.Code)
 
-        var createdProduct entity.Product
+        var createdProduct dto.ProductResponse
         json.Unmarshal(w.Body.Bytes(), &createdProduct)
         assert.NotZero(t, createdProduct.ID)
 
@@ -83,6 +132,20 @@ func TestProductE2E(t *testing.T) {
         router.ServeHTTP(w, req)
         assert.Equal(t, http.StatusBadRequest, w.Code)
 
+        // Create Product - Unknown Category
+        orphanProduct := productRequest("Orphan Product", 9.99, 999999)
+        body, _ = json.Marshal(orphanProduct)
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("POST", "/api/v1/products", bytes.NewBuffer(body))
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusConflict, w.Code)
+
+        // Get Product - Invalid ID
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("GET", "/api/v1/products/abc", nil)
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusBadRequest, w.Code)
+
         // Get Product - Success
         w = httptest.NewRecorder()
         req, _ = http.NewRequest("GET", fmt.Sprintf("/api/v1/products/%d", createdProduct.ID), nil)
@@ -96,13 +159,75 @@ func TestProductE2E(t *testing.T) {
         assert.Equal(t, http.StatusNotFound, w.Code)
 
         // Update Product - Success
-        updatedProduct := entity.Product{Name: "Updated Product", Price: 19.99, CategoryID: createdCategory.ID}
+        updatedProduct := productRequest("Updated Product", 19.99, createdCategory.ID)
         body, _ = json.Marshal(updatedProduct)
         w = httptest.NewRecorder()
         req, _ = http.NewRequest("PUT", fmt.Sprintf("/api/v1/products/%d", createdProduct.ID), bytes.NewBuffer(body))
         router.ServeHTTP(w, req)
         assert.Equal(t, http.StatusOK, w.Code)
 
+        // Patch Product - Merge Patch keeps omitted fields
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("PATCH", fmt.Sprintf("/api/v1/products/%d", createdProduct.ID), bytes.NewBufferString(`{"price": 5}`))
+        req.Header.Set("Content-Type", "application/merge-patch+json")
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusOK, w.Code)
+
+        var patchedProduct dto.ProductResponse
+        json.Unmarshal(w.Body.Bytes(), &patchedProduct)
+        assert.Equal(t, "Updated Product", patchedProduct.Name)
+        assert.Equal(t, 5.0, patchedProduct.Price)
+        assert.Equal(t, createdCategory.ID, patchedProduct.CategoryID)
+
+        // Patch Product - JSON Patch
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("PATCH", fmt.Sprintf("/api/v1/products/%d", createdProduct.ID), bytes.NewBufferString(`[
+            {"op": "test", "path": "/price", "value": 5},
+            {"op": "replace", "path": "/name", "value": "Patched Product"}
+        ]`))
+        req.Header.Set("Content-Type", "application/json-patch+json")
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusOK, w.Code)
+
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("GET", fmt.Sprintf("/api/v1/products/%d", createdProduct.ID), nil)
+        router.ServeHTTP(w, req)
+        json.Unmarshal(w.Body.Bytes(), &patchedProduct)
+        assert.Equal(t, "Patched Product", patchedProduct.Name)
+        assert.Equal(t, 5.0, patchedProduct.Price)
+
+        // Patch Product - Failed test operation
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("PATCH", fmt.Sprintf("/api/v1/products/%d", createdProduct.ID), bytes.NewBufferString(`[{"op": "test", "path": "/price", "value": 6}]`))
+        req.Header.Set("Content-Type", "application/json-patch+json")
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusConflict, w.Code)
+
+        // Patch Product - Result is validated
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("PATCH", fmt.Sprintf("/api/v1/products/%d", createdProduct.ID), bytes.NewBufferString(`{"name": null}`))
+        req.Header.Set("Content-Type", "application/merge-patch+json")
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusBadRequest, w.Code)
+
+        // Patch Product - Unsupported patch format
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("PATCH", fmt.Sprintf("/api/v1/products/%d", createdProduct.ID), bytes.NewBufferString(`name=x`))
+        req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+        // Update Product - Invalid Data
+        body, _ = json.Marshal(map[string]interface{}{"name": "  ", "price": -5, "category_id": createdCategory.ID})
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("PUT", fmt.Sprintf("/api/v1/products/%d", createdProduct.ID), bytes.NewBuffer(body))
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusBadRequest, w.Code)
+
+        var problem errors.Problem
+        json.Unmarshal(w.Body.Bytes(), &problem)
+        assert.Len(t, problem.Errors, 2)
+
         // Update Product - Not Found
         w = httptest.NewRecorder()
         req, _ = http.NewRequest("PUT", "/api/v1/products/999", bytes.NewBuffer(body))
@@ -128,14 +253,14 @@ func TestCategoryE2E(t *testing.T) {
 
     t.Run("Category CRUD Operations", func(t *testing.T) {
         // Create Category - Success
-        category := entity.Category{Name: "Test Category"}
+        category := dto.CategoryRequest{Name: "Test Category"}
         body, _ := json.Marshal(category)
         w := httptest.NewRecorder()
         req, _ := http.NewRequest("POST", "/api/v1/categories", bytes.NewBuffer(body))
         router.ServeHTTP(w, req)
         assert.Equal(t, http.StatusCreated, w.Code)
 
-        var createdCategory entity.Category
+        var createdCategory dto.CategoryResponse
         json.Unmarshal(w.Body.Bytes(), &createdCategory)
         assert.NotZero(t, createdCategory.ID)
 
@@ -160,7 +285,7 @@ func TestCategoryE2E(t *testing.T) {
         assert.Equal(t, http.StatusNotFound, w.Code)
 
         // Update Category - Success
-        updatedCategory := entity.Category{Name: "Updated Category"}
+        updatedCategory := dto.CategoryRequest{Name: "Updated Category"}
         body, _ = json.Marshal(updatedCategory)
         w = httptest.NewRecorder()
         req, _ = http.NewRequest("PUT", fmt.Sprintf("/api/v1/categories/%d", createdCategory.ID), bytes.NewBuffer(body))
@@ -186,3 +311,867 @@ func TestCategoryE2E(t *testing.T) {
         assert.Equal(t, http.StatusNotFound, w.Code)
     })
 }
+
+func TestProductListE2E(t *testing.T) {
+    router := setupTestEnvironment(t)
+
+    t.Run("Product Listing", func(t *testing.T) {
+        category := dto.CategoryRequest{Name: "List Category"}
+        body, _ := json.Marshal(category)
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("POST", "/api/v1/categories", bytes.NewBuffer(body))
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusCreated, w.Code)
+
+        var createdCategory dto.CategoryResponse
+        json.Unmarshal(w.Body.Bytes(), &createdCategory)
+
+        for i, price := range []float64{5, 15, 25} {
+            product := productRequest(fmt.Sprintf("Listed Product %d", i), price, createdCategory.ID)
+            body, _ = json.Marshal(product)
+            w = httptest.NewRecorder()
+            req, _ = http.NewRequest("POST", "/api/v1/products", bytes.NewBuffer(body))
+            router.ServeHTTP(w, req)
+            assert.Equal(t, http.StatusCreated, w.Code)
+        }
+
+        // Filter, sort and paginate
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("GET", fmt.Sprintf("/api/v1/products?category_id=%d&min_price=10&sort=-price&page_size=1", createdCategory.ID), nil)
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusOK, w.Code)
+
+        var page struct {
+            Data  []dto.ProductResponse `json:"data"`
+            Meta  struct {
+                Total      int64 `json:"total"`
+                TotalPages int   `json:"total_pages"`
+            } `json:"meta"`
+            Links struct {
+                Next string `json:"next"`
+                Prev string `json:"prev"`
+            } `json:"links"`
+        }
+        json.Unmarshal(w.Body.Bytes(), &page)
+        assert.Equal(t, int64(2), page.Meta.Total)
+        assert.Equal(t, 2, page.Meta.TotalPages)
+        assert.Len(t, page.Data, 1)
+        assert.Equal(t, 25.0, page.Data[0].Price)
+        assert.Contains(t, page.Links.Next, "page=2")
+        assert.Empty(t, page.Links.Prev)
+
+        // Invalid sort field
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("GET", "/api/v1/products?sort=unknown", nil)
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusBadRequest, w.Code)
+    })
+}
+
+func TestCursorPaginationE2E(t *testing.T) {
+    router := setupTestEnvironment(t)
+
+    t.Run("Category Cursor Walk", func(t *testing.T) {
+        created := map[uint]bool{}
+        for _, name := range []string{"Cursor B", "Cursor A", "Cursor C"} {
+            body, _ := json.Marshal(dto.CategoryRequest{Name: name})
+            w := httptest.NewRecorder()
+            req, _ := http.NewRequest("POST", "/api/v1/categories", bytes.NewBuffer(body))
+            router.ServeHTTP(w, req)
+            assert.Equal(t, http.StatusCreated, w.Code)
+
+            var category dto.CategoryResponse
+            json.Unmarshal(w.Body.Bytes(), &category)
+            created[category.ID] = true
+        }
+
+        var names []string
+        url := "/api/v1/categories?sort=name&page_size=2&cursor="
+        for url != "" {
+            w := httptest.NewRecorder()
+            req, _ := http.NewRequest("GET", url, nil)
+            router.ServeHTTP(w, req)
+            assert.Equal(t, http.StatusOK, w.Code)
+
+            var page struct {
+                Data       []dto.CategoryResponse `json:"data"`
+                NextCursor string            `json:"next_cursor"`
+                Links      struct {
+                    Next string `json:"next"`
+                } `json:"links"`
+            }
+            json.Unmarshal(w.Body.Bytes(), &page)
+            for _, category := range page.Data {
+                assert.True(t, created[category.ID])
+                names = append(names, category.Name)
+            }
+            url = page.Links.Next
+        }
+        assert.Equal(t, []string{"Cursor A", "Cursor B", "Cursor C"}, names)
+
+        // Tampered cursor
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("GET", "/api/v1/categories?cursor=eyJzIjoiaWQiLCJpIjo5OTl9.AAAA", nil)
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusBadRequest, w.Code)
+    })
+}
+
+func TestCacheInvalidationE2E(t *testing.T) {
+    cfg := config.Load()
+    if cfg.CacheDriver != cache.DriverRedis {
+        t.Skip("cache invalidation test inspects Redis directly")
+    }
+    router := setupTestEnvironment(t)
+
+    redisClient, err := cache.NewRedisClient(cfg.RedisURL)
+    assert.NoError(t, err)
+    ctx := context.Background()
+
+    get := func(path string) {
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("GET", path, nil)
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusOK, w.Code)
+    }
+    cached := func(key string) bool {
+        n, err := redisClient.Client.Exists(ctx, key).Result()
+        assert.NoError(t, err)
+        return n == 1
+    }
+
+    t.Run("Writes Only Drop Related Keys", func(t *testing.T) {
+        assert.NoError(t, redisClient.Client.Set(ctx, "session:e2e", "alive", time.Minute).Err())
+
+        first := createCategory(t, router, "First", nil)
+        second := createCategory(t, router, "Second", nil)
+        p1 := createProduct(t, router, "P1", first.ID)
+        p2 := createProduct(t, router, "P2", first.ID)
+        p3 := createProduct(t, router, "P3", second.ID)
+
+        for _, path := range []string{
+            fmt.Sprintf("/api/v1/categories/%d", first.ID),
+            fmt.Sprintf("/api/v1/categories/%d", second.ID),
+            fmt.Sprintf("/api/v1/products/%d", p1.ID),
+            fmt.Sprintf("/api/v1/products/%d", p2.ID),
+            fmt.Sprintf("/api/v1/products/%d", p3.ID),
+        } {
+            get(path)
+        }
+
+        // Updating a product only drops that product
+        body, _ := json.Marshal(productRequest("P1 updated", 2, first.ID))
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/v1/products/%d", p1.ID), bytes.NewBuffer(body))
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusOK, w.Code)
+
+        assert.False(t, cached(fmt.Sprintf("product:%d", p1.ID)))
+        assert.True(t, cached(fmt.Sprintf("product:%d", p2.ID)))
+        assert.True(t, cached(fmt.Sprintf("category:%d", first.ID)))
+        assert.True(t, cached("session:e2e"))
+
+        // Updating a category drops it and the products embedding it
+        body, _ = json.Marshal(dto.CategoryRequest{Name: "First updated"})
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("PUT", fmt.Sprintf("/api/v1/categories/%d", first.ID), bytes.NewBuffer(body))
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusOK, w.Code)
+
+        assert.False(t, cached(fmt.Sprintf("category:%d", first.ID)))
+        assert.False(t, cached(fmt.Sprintf("product:%d", p2.ID)))
+        assert.True(t, cached(fmt.Sprintf("category:%d", second.ID)))
+        assert.True(t, cached(fmt.Sprintf("product:%d", p3.ID)))
+        assert.True(t, cached("session:e2e"))
+
+        // The embedded category is fresh after invalidation
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("GET", fmt.Sprintf("/api/v1/products/%d?include=category", p2.ID), nil)
+        router.ServeHTTP(w, req)
+        var product dto.ProductResponse
+        json.Unmarshal(w.Body.Bytes(), &product)
+        if assert.NotNil(t, product.Category) {
+            assert.Equal(t, "First updated", product.Category.Name)
+        }
+    })
+}
+
+func TestListCacheE2E(t *testing.T) {
+    router := setupTestEnvironment(t)
+
+    t.Run("Writes Invalidate Cached Lists", func(t *testing.T) {
+        body, _ := json.Marshal(dto.CategoryRequest{Name: "Before"})
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("POST", "/api/v1/categories", bytes.NewBuffer(body))
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusCreated, w.Code)
+
+        var category dto.CategoryResponse
+        json.Unmarshal(w.Body.Bytes(), &category)
+
+        listNames := func() []string {
+            w := httptest.NewRecorder()
+            req, _ := http.NewRequest("GET", "/api/v1/categories", nil)
+            router.ServeHTTP(w, req)
+            assert.Equal(t, http.StatusOK, w.Code)
+
+            var categories []dto.CategoryResponse
+            json.Unmarshal(w.Body.Bytes(), &categories)
+            var names []string
+            for _, c := range categories {
+                names = append(names, c.Name)
+            }
+            return names
+        }
+
+        assert.Equal(t, []string{"Before"}, listNames())
+        assert.Equal(t, []string{"Before"}, listNames())
+
+        body, _ = json.Marshal(dto.CategoryRequest{Name: "After"})
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("PUT", fmt.Sprintf("/api/v1/categories/%d", category.ID), bytes.NewBuffer(body))
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusOK, w.Code)
+
+        assert.Equal(t, []string{"After"}, listNames())
+    })
+}
+
+func TestOptimisticConcurrencyE2E(t *testing.T) {
+    router := setupTestEnvironment(t)
+
+    t.Run("If-Match Guards Writes", func(t *testing.T) {
+        body, _ := json.Marshal(dto.CategoryRequest{Name: "Versioned"})
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("POST", "/api/v1/categories", bytes.NewBuffer(body))
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusCreated, w.Code)
+        assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+
+        var category dto.CategoryResponse
+        json.Unmarshal(w.Body.Bytes(), &category)
+        path := fmt.Sprintf("/api/v1/categories/%d", category.ID)
+
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("GET", path, nil)
+        router.ServeHTTP(w, req)
+        etag := w.Header().Get("ETag")
+        assert.Equal(t, `"1"`, etag)
+
+        // The first writer wins and bumps the version
+        body, _ = json.Marshal(dto.CategoryRequest{Name: "First writer"})
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("PUT", path, bytes.NewBuffer(body))
+        req.Header.Set("If-Match", etag)
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusOK, w.Code)
+        assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+
+        // The second writer still holds the old ETag
+        body, _ = json.Marshal(dto.CategoryRequest{Name: "Second writer"})
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("PUT", path, bytes.NewBuffer(body))
+        req.Header.Set("If-Match", etag)
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("DELETE", path, nil)
+        req.Header.Set("If-Match", etag)
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("GET", path, nil)
+        router.ServeHTTP(w, req)
+        json.Unmarshal(w.Body.Bytes(), &category)
+        assert.Equal(t, "First writer", category.Name)
+        assert.Equal(t, uint(2), category.Version)
+
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("DELETE", path, nil)
+        req.Header.Set("If-Match", `"2"`)
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusOK, w.Code)
+    })
+}
+
+func TestConditionalGetE2E(t *testing.T) {
+    router := setupTestEnvironment(t)
+
+    get := func(path string, header, value string) *httptest.ResponseRecorder {
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("GET", path, nil)
+        if header != "" {
+            req.Header.Set(header, value)
+        }
+        router.ServeHTTP(w, req)
+        return w
+    }
+
+    t.Run("Items And Lists Revalidate", func(t *testing.T) {
+        body, _ := json.Marshal(dto.CategoryRequest{Name: "Conditional"})
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("POST", "/api/v1/categories", bytes.NewBuffer(body))
+        router.ServeHTTP(w, req)
+        var category dto.CategoryResponse
+        json.Unmarshal(w.Body.Bytes(), &category)
+
+        body, _ = json.Marshal(productRequest("Conditional Product", 10, category.ID))
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("POST", "/api/v1/products", bytes.NewBuffer(body))
+        router.ServeHTTP(w, req)
+        var product dto.ProductResponse
+        json.Unmarshal(w.Body.Bytes(), &product)
+
+        // Items
+        path := fmt.Sprintf("/api/v1/products/%d", product.ID)
+        w = get(path, "", "")
+        assert.Equal(t, http.StatusOK, w.Code)
+        assert.NotEmpty(t, w.Header().Get("Cache-Control"))
+        assert.NotEmpty(t, w.Header().Get("Last-Modified"))
+        assert.Equal(t, http.StatusNotModified, get(path, "If-None-Match", w.Header().Get("ETag")).Code)
+        assert.Equal(t, http.StatusNotModified, get(path, "If-Modified-Since", w.Header().Get("Last-Modified")).Code)
+
+        // Lists
+        w = get("/api/v1/products", "", "")
+        assert.Equal(t, http.StatusOK, w.Code)
+        listETag := w.Header().Get("ETag")
+        assert.NotEmpty(t, listETag)
+        assert.Equal(t, http.StatusNotModified, get("/api/v1/products", "If-None-Match", listETag).Code)
+
+        // Embedding categories makes category changes visible in the list ETag
+        w = get("/api/v1/products?include=category", "", "")
+        embeddedETag := w.Header().Get("ETag")
+
+        body, _ = json.Marshal(dto.CategoryRequest{Name: "Conditional renamed"})
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("PUT", fmt.Sprintf("/api/v1/categories/%d", category.ID), bytes.NewBuffer(body))
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusOK, w.Code)
+
+        assert.Equal(t, http.StatusNotModified, get("/api/v1/products", "If-None-Match", listETag).Code)
+        assert.Equal(t, http.StatusOK, get("/api/v1/products?include=category", "If-None-Match", embeddedETag).Code)
+
+        // Deleting a product changes the list
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("DELETE", path, nil)
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusOK, w.Code)
+        assert.Equal(t, http.StatusOK, get("/api/v1/products", "If-None-Match", listETag).Code)
+    })
+}
+
+func TestIdempotencyE2E(t *testing.T) {
+    router := setupTestEnvironment(t)
+
+    post := func(key string, body []byte) *httptest.ResponseRecorder {
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("POST", "/api/v1/categories", bytes.NewBuffer(body))
+        req.Header.Set("Idempotency-Key", key)
+        router.ServeHTTP(w, req)
+        return w
+    }
+
+    t.Run("Retries Replay The First Response", func(t *testing.T) {
+        key := fmt.Sprintf("e2e-%d", time.Now().UnixNano())
+        body, _ := json.Marshal(dto.CategoryRequest{Name: "Idempotent"})
+
+        first := post(key, body)
+        assert.Equal(t, http.StatusCreated, first.Code)
+
+        second := post(key, body)
+        assert.Equal(t, http.StatusCreated, second.Code)
+        assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+        assert.Equal(t, first.Body.String(), second.Body.String())
+
+        body, _ = json.Marshal(dto.CategoryRequest{Name: "Something else"})
+        assert.Equal(t, http.StatusUnprocessableEntity, post(key, body).Code)
+    })
+}
+
+func TestProductBatchE2E(t *testing.T) {
+    router := setupTestEnvironment(t)
+
+    batch := func(body string) (*httptest.ResponseRecorder, dto.ProductBatchResponse) {
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("POST", "/api/v1/products:batch", bytes.NewBufferString(body))
+        req.Header.Set("Content-Type", "application/json")
+        router.ServeHTTP(w, req)
+        var response dto.ProductBatchResponse
+        json.Unmarshal(w.Body.Bytes(), &response)
+        return w, response
+    }
+
+    body, _ := json.Marshal(dto.CategoryRequest{Name: "Batch"})
+    w := httptest.NewRecorder()
+    req, _ := http.NewRequest("POST", "/api/v1/categories", bytes.NewBuffer(body))
+    router.ServeHTTP(w, req)
+    var category dto.CategoryResponse
+    json.Unmarshal(w.Body.Bytes(), &category)
+
+    t.Run("Atomic Batch", func(t *testing.T) {
+        w, response := batch(fmt.Sprintf(`{"operations": [
+            {"op": "create", "product": {"name": "Batch A", "price": 1, "category_id": %d}},
+            {"op": "create", "product": {"name": "Batch B", "price": 2, "category_id": %d}}
+        ]}`, category.ID, category.ID))
+        assert.Equal(t, http.StatusOK, w.Code)
+        assert.Equal(t, 2, response.Succeeded)
+        assert.Equal(t, http.StatusCreated, response.Results[0].Status)
+        created := response.Results[0].Product
+
+        w, response = batch(fmt.Sprintf(`{"operations": [
+            {"op": "update", "id": %d, "version": 1, "product": {"name": "Batch A2", "price": 10, "category_id": %d}},
+            {"op": "delete", "id": %d},
+            {"op": "create", "product": {"name": "Batch C", "price": 3, "category_id": 999999}}
+        ]}`, created.ID, category.ID, response.Results[1].Product.ID))
+        assert.Equal(t, http.StatusConflict, w.Code)
+        assert.Contains(t, w.Body.String(), "operations[2]")
+
+        // Nothing was written
+        w = httptest.NewRecorder()
+        req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/products/%d", created.ID), nil)
+        router.ServeHTTP(w, req)
+        var product dto.ProductResponse
+        json.Unmarshal(w.Body.Bytes(), &product)
+        assert.Equal(t, "Batch A", product.Name)
+    })
+
+    t.Run("Best Effort Batch", func(t *testing.T) {
+        w, response := batch(fmt.Sprintf(`{"mode": "best_effort", "operations": [
+            {"op": "create", "product": {"name": "Batch D", "price": 4, "category_id": %d}},
+            {"op": "create", "product": {"name": "", "price": 5, "category_id": %d}},
+            {"op": "delete", "id": 999999}
+        ]}`, category.ID, category.ID))
+        assert.Equal(t, http.StatusOK, w.Code)
+        assert.Equal(t, 1, response.Succeeded)
+        assert.Equal(t, 2, response.Failed)
+        assert.Equal(t, http.StatusCreated, response.Results[0].Status)
+        assert.Equal(t, http.StatusBadRequest, response.Results[1].Status)
+        assert.Equal(t, http.StatusNotFound, response.Results[2].Status)
+
+        // The created product is visible right away
+        w = httptest.NewRecorder()
+        req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/products/%d", response.Results[0].Product.ID), nil)
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusOK, w.Code)
+    })
+
+    t.Run("Invalid Requests", func(t *testing.T) {
+        w, _ := batch(`{}`)
+        assert.Equal(t, http.StatusBadRequest, w.Code)
+
+        w = httptest.NewRecorder()
+        req, _ := http.NewRequest("POST", "/api/v1/products:frobnicate", bytes.NewBufferString(`{}`))
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusNotFound, w.Code)
+    })
+}
+
+func TestProductImportE2E(t *testing.T) {
+    router := setupTestEnvironment(t)
+
+    importFile := func(query, contentType, body string) (*httptest.ResponseRecorder, dto.ProductImportResponse) {
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("POST", "/api/v1/products:import"+query, bytes.NewBufferString(body))
+        req.Header.Set("Content-Type", contentType)
+        router.ServeHTTP(w, req)
+        var response dto.ProductImportResponse
+        json.Unmarshal(w.Body.Bytes(), &response)
+        return w, response
+    }
+
+    suffix := time.Now().UnixNano()
+    csv := fmt.Sprintf("external_key,name,price,category\n"+
+        "IMP-%[1]d-1,Imported Phone,10,Imported %[1]d\n"+
+        "IMP-%[1]d-2,Imported Desk,20,Imported %[1]d\n", suffix)
+
+    t.Run("Unknown Categories Are Rejected", func(t *testing.T) {
+        w, _ := importFile("", "text/csv", csv)
+        assert.Equal(t, http.StatusBadRequest, w.Code)
+        assert.Contains(t, w.Body.String(), `"row":2`)
+    })
+
+    t.Run("Dry Run Writes Nothing", func(t *testing.T) {
+        w, report := importFile("?dry_run=true&create_categories=true", "text/csv", csv)
+        assert.Equal(t, http.StatusOK, w.Code)
+        assert.True(t, report.DryRun)
+        assert.Equal(t, 2, report.Created)
+        assert.Len(t, report.CategoriesCreated, 1)
+
+        w, _ = importFile("", "text/csv", csv)
+        assert.Equal(t, http.StatusBadRequest, w.Code)
+    })
+
+    t.Run("Import Is Idempotent", func(t *testing.T) {
+        w, report := importFile("?create_categories=true", "text/csv", csv)
+        assert.Equal(t, http.StatusOK, w.Code)
+        assert.Equal(t, 2, report.Created)
+
+        w, report = importFile("?create_categories=true", "text/csv", csv)
+        assert.Equal(t, http.StatusOK, w.Code)
+        assert.Equal(t, 0, report.Created)
+        assert.Equal(t, 2, report.Unchanged)
+        assert.Empty(t, report.CategoriesCreated)
+
+        ndjson := fmt.Sprintf(`{"external_key": "IMP-%[1]d-1", "name": "Imported Phone", "price": 15, "category": "imported %[1]d"}`, suffix)
+        w, report = importFile("", "application/x-ndjson", ndjson)
+        assert.Equal(t, http.StatusOK, w.Code)
+        assert.Equal(t, 1, report.Updated)
+    })
+
+    t.Run("Unsupported Content Type", func(t *testing.T) {
+        w, _ := importFile("", "application/json", `{}`)
+        assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+    })
+}
+
+func TestProductExportE2E(t *testing.T) {
+    router := setupTestEnvironment(t)
+
+    body, _ := json.Marshal(dto.CategoryRequest{Name: "Export"})
+    w := httptest.NewRecorder()
+    req, _ := http.NewRequest("POST", "/api/v1/categories", bytes.NewBuffer(body))
+    router.ServeHTTP(w, req)
+    var category dto.CategoryResponse
+    json.Unmarshal(w.Body.Bytes(), &category)
+
+    for _, name := range []string{"Export A", "Export B"} {
+        body, _ = json.Marshal(productRequest(name, 5, category.ID))
+        w = httptest.NewRecorder()
+        req, _ = http.NewRequest("POST", "/api/v1/products", bytes.NewBuffer(body))
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusCreated, w.Code)
+    }
+
+    export := func(query string) *httptest.ResponseRecorder {
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("GET", "/api/v1/products/export"+query, nil)
+        router.ServeHTTP(w, req)
+        return w
+    }
+
+    t.Run("CSV", func(t *testing.T) {
+        w := export(fmt.Sprintf("?category_id=%d&sort=-name", category.ID))
+        assert.Equal(t, http.StatusOK, w.Code)
+        assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment;")
+        lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+        if assert.Len(t, lines, 3) {
+            assert.True(t, strings.HasPrefix(lines[0], "id,external_key,name,"))
+            assert.Contains(t, lines[1], "Export B")
+            assert.Contains(t, lines[1], ",Export,")
+        }
+    })
+
+    t.Run("NDJSON", func(t *testing.T) {
+        w := export(fmt.Sprintf("?format=ndjson&category_id=%d", category.ID))
+        assert.Equal(t, http.StatusOK, w.Code)
+        assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+        var row map[string]interface{}
+        assert.NoError(t, json.Unmarshal([]byte(strings.Split(w.Body.String(), "\n")[0]), &row))
+        assert.Equal(t, "Export", row["category_name"])
+    })
+
+    t.Run("XLSX", func(t *testing.T) {
+        w := export(fmt.Sprintf("?format=xlsx&category_id=%d", category.ID))
+        assert.Equal(t, http.StatusOK, w.Code)
+        _, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+        assert.NoError(t, err)
+    })
+
+    t.Run("Unknown Format", func(t *testing.T) {
+        assert.Equal(t, http.StatusBadRequest, export("?format=pdf").Code)
+    })
+}
+
+func TestCategoryHierarchyE2E(t *testing.T) {
+    router := setupTestEnvironment(t)
+
+    get := func(path string, target interface{}) int {
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("GET", path, nil)
+        router.ServeHTTP(w, req)
+        json.Unmarshal(w.Body.Bytes(), target)
+        return w.Code
+    }
+    names := func(categories []dto.CategoryResponse) []string {
+        var names []string
+        for _, category := range categories {
+            names = append(names, category.Name)
+        }
+        return names
+    }
+
+    root := createCategory(t, router, "Hierarchy", nil)
+    phones := createCategory(t, router, "Phones", &root.ID)
+    android := createCategory(t, router, "Android", &phones.ID)
+    laptops := createCategory(t, router, "Laptops", &root.ID)
+
+    t.Run("Children", func(t *testing.T) {
+        var children []dto.CategoryResponse
+        assert.Equal(t, http.StatusOK, get(fmt.Sprintf("/api/v1/categories/%d/children", root.ID), &children))
+        assert.Equal(t, []string{"Laptops", "Phones"}, names(children))
+    })
+
+    t.Run("Ancestors", func(t *testing.T) {
+        var ancestors []dto.CategoryResponse
+        assert.Equal(t, http.StatusOK, get(fmt.Sprintf("/api/v1/categories/%d/ancestors", android.ID), &ancestors))
+        assert.Equal(t, []string{"Hierarchy", "Phones"}, names(ancestors))
+    })
+
+    t.Run("Descendants", func(t *testing.T) {
+        var descendants []dto.CategoryResponse
+        assert.Equal(t, http.StatusOK, get(fmt.Sprintf("/api/v1/categories/%d/descendants", root.ID), &descendants))
+        assert.Equal(t, []string{"Laptops", "Phones", "Android"}, names(descendants))
+        assert.Equal(t, http.StatusNotFound, get("/api/v1/categories/999999/descendants", &descendants))
+    })
+
+    t.Run("Tree", func(t *testing.T) {
+        var tree []dto.CategoryTreeNode
+        assert.Equal(t, http.StatusOK, get("/api/v1/categories/tree", &tree))
+        for _, node := range tree {
+            if node.ID == root.ID && assert.Len(t, node.Children, 2) {
+                assert.Equal(t, "Phones", node.Children[1].Name)
+                assert.Len(t, node.Children[1].Children, 1)
+            }
+        }
+    })
+
+    t.Run("Cycle", func(t *testing.T) {
+        body, _ := json.Marshal(dto.CategoryRequest{Name: "Hierarchy", ParentID: &android.ID})
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("PUT", fmt.Sprintf("/api/v1/categories/%d", root.ID), bytes.NewBuffer(body))
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusBadRequest, w.Code)
+        assert.Contains(t, w.Body.String(), "category_cycle")
+    })
+
+    t.Run("Move", func(t *testing.T) {
+        body := []byte(fmt.Sprintf(`{"parent_id": %d}`, phones.ID))
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("PATCH", fmt.Sprintf("/api/v1/categories/%d", laptops.ID), bytes.NewBuffer(body))
+        req.Header.Set("Content-Type", "application/merge-patch+json")
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusOK, w.Code)
+
+        var children []dto.CategoryResponse
+        get(fmt.Sprintf("/api/v1/categories/%d/children", phones.ID), &children)
+        assert.Equal(t, []string{"Android", "Laptops"}, names(children))
+    })
+
+    t.Run("Products Of Subcategories", func(t *testing.T) {
+        body, _ := json.Marshal(productRequest("Pixel", 599, android.ID))
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("POST", "/api/v1/products", bytes.NewBuffer(body))
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusCreated, w.Code)
+
+        var page struct {
+            Data []dto.ProductResponse `json:"data"`
+        }
+        get(fmt.Sprintf("/api/v1/products?category_id=%d", root.ID), &page)
+        assert.Empty(t, page.Data)
+        get(fmt.Sprintf("/api/v1/products?category_id=%d&include_subcategories=true", root.ID), &page)
+        if assert.Len(t, page.Data, 1) {
+            assert.Equal(t, "Pixel", page.Data[0].Name)
+        }
+    })
+
+    t.Run("Delete Parent", func(t *testing.T) {
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("DELETE", fmt.Sprintf("/api/v1/categories/%d", phones.ID), nil)
+        router.ServeHTTP(w, req)
+        assert.Equal(t, http.StatusConflict, w.Code)
+    })
+}
+
+func TestCategoryDeletePoliciesE2E(t *testing.T) {
+    router := setupTestEnvironment(t)
+
+    deleteCategory := func(id uint, query string) *httptest.ResponseRecorder {
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("DELETE", fmt.Sprintf("/api/v1/categories/%d%s", id, query), nil)
+        router.ServeHTTP(w, req)
+        return w
+    }
+    getProduct := func(id uint) (int, dto.ProductResponse) {
+        w := httptest.NewRecorder()
+        req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/products/%d", id), nil)
+        router.ServeHTTP(w, req)
+        var product dto.ProductResponse
+        json.Unmarshal(w.Body.Bytes(), &product)
+        return w.Code, product
+    }
+
+    t.Run("Restrict", func(t *testing.T) {
+        categoryID := createCategory(t, router, "Restrict", nil).ID
+        createProduct(t, router, "Kept", categoryID)
+        createProduct(t, router, "Kept Too", categoryID)
+
+        w := deleteCategory(categoryID, "")
+        assert.Equal(t, http.StatusConflict, w.Code)
+        assert.Contains(t, w.Body.String(), "category still has 2 products")
+        assert.Contains(t, w.Body.String(), "category_has_products")
+    })
+
+    t.Run("Reassign", func(t *testing.T) {
+        categoryID := createCategory(t, router, "Reassign From", nil).ID
+        targetID := createCategory(t, router, "Reassign To", nil).ID
+        productID := createProduct(t, router, "Moved", categoryID).ID
+        _, before := getProduct(productID)
+
+        assert.Equal(t, http.StatusBadRequest, deleteCategory(categoryID, "?on_products=reassign").Code)
+        assert.Equal(t, http.StatusBadRequest, deleteCategory(categoryID, "?on_products=reassign&target_category_id=999999").Code)
+
+        w := deleteCategory(categoryID, fmt.Sprintf("?on_products=reassign&target_category_id=%d", targetID))
+        assert.Equal(t, http.StatusOK, w.Code)
+        code, product := getProduct(productID)
+        assert.Equal(t, http.StatusOK, code)
+        assert.Equal(t, targetID, product.CategoryID)
+        assert.Equal(t, before.Version+1, product.Version)
+    })
+
+    t.Run("Cascade", func(t *testing.T) {
+        categoryID := createCategory(t, router, "Cascade", nil).ID
+        productID := createProduct(t, router, "Gone", categoryID).ID
+
+        assert.Equal(t, http.StatusOK, deleteCategory(categoryID, "?on_products=cascade").Code)
+        code, _ := getProduct(productID)
+        assert.Equal(t, http.StatusNotFound, code)
+    })
+
+    t.Run("Unknown Policy", func(t *testing.T) {
+        assert.Equal(t, http.StatusBadRequest, deleteCategory(1, "?on_products=orphan").Code)
+    })
+}
+
+func TestTrashE2E(t *testing.T) {
+    router := setupTestEnvironment(t)
+
+    category := createCategory(t, router, "Trash", nil)
+    product := createProduct(t, router, "Trashed", category.ID)
+
+    assert.Equal(t, http.StatusOK, do(router, "DELETE", fmt.Sprintf("/api/v1/categories/%d?on_products=cascade", category.ID), nil).Code)
+
+    t.Run("List", func(t *testing.T) {
+        var page struct {
+            Data []dto.DeletedProductResponse `json:"data"`
+        }
+        w := do(router, "GET", "/api/v1/trash/products?page_size=100", nil)
+        assert.Equal(t, http.StatusOK, w.Code)
+        json.Unmarshal(w.Body.Bytes(), &page)
+        found := false
+        for _, deleted := range page.Data {
+            if deleted.ID == product.ID {
+                found = true
+                assert.False(t, deleted.DeletedAt.IsZero())
+            }
+        }
+        assert.True(t, found)
+    })
+
+    t.Run("Restore", func(t *testing.T) {
+        productPath := fmt.Sprintf("/api/v1/trash/products/%d", product.ID)
+        categoryPath := fmt.Sprintf("/api/v1/trash/categories/%d", category.ID)
+
+        // The category has to come back first
+        w := do(router, "POST", productPath+"/restore", nil)
+        assert.Equal(t, http.StatusConflict, w.Code)
+        assert.Contains(t, w.Body.String(), "category_deleted")
+
+        assert.Equal(t, http.StatusOK, do(router, "POST", categoryPath+"/restore", nil).Code)
+        assert.Equal(t, http.StatusNotFound, do(router, "POST", categoryPath+"/restore", nil).Code)
+
+        w = do(router, "POST", productPath+"/restore", nil)
+        assert.Equal(t, http.StatusOK, w.Code)
+        var restored dto.ProductResponse
+        json.Unmarshal(w.Body.Bytes(), &restored)
+        assert.Equal(t, product.Version+1, restored.Version)
+        assert.Equal(t, http.StatusOK, do(router, "GET", fmt.Sprintf("/api/v1/products/%d", product.ID), nil).Code)
+    })
+
+    t.Run("Purge", func(t *testing.T) {
+        assert.Equal(t, http.StatusOK, do(router, "DELETE", fmt.Sprintf("/api/v1/products/%d", product.ID), nil).Code)
+        assert.Equal(t, http.StatusOK, do(router, "DELETE", fmt.Sprintf("/api/v1/categories/%d", category.ID), nil).Code)
+
+        w := do(router, "DELETE", fmt.Sprintf("/api/v1/trash/categories/%d", category.ID), nil)
+        assert.Equal(t, http.StatusConflict, w.Code)
+        assert.Contains(t, w.Body.String(), "category_in_use")
+
+        assert.Equal(t, http.StatusOK, do(router, "DELETE", fmt.Sprintf("/api/v1/trash/products/%d", product.ID), nil).Code)
+        assert.Equal(t, http.StatusOK, do(router, "DELETE", fmt.Sprintf("/api/v1/trash/categories/%d", category.ID), nil).Code)
+        assert.Equal(t, http.StatusNotFound, do(router, "POST", fmt.Sprintf("/api/v1/trash/products/%d/restore", product.ID), nil).Code)
+    })
+}
+
+func TestAuditLogE2E(t *testing.T) {
+    router := setupTestEnvironment(t)
+
+    audited := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+        req.Header.Set("X-Actor", "alice")
+        req.Header.Set("X-Request-ID", "audit-e2e")
+        router.ServeHTTP(w, req)
+    })
+    type history struct {
+        Data []struct {
+            Action    string                     `json:"action"`
+            Actor     string                     `json:"actor"`
+            RequestID string                     `json:"request_id"`
+            Changes   map[string]json.RawMessage `json:"changes"`
+        } `json:"data"`
+        Meta struct {
+            Total int64 `json:"total"`
+        } `json:"meta"`
+    }
+    getHistory := func(path string) (int, history) {
+        w := do(audited, "GET", path, nil)
+        var page history
+        json.Unmarshal(w.Body.Bytes(), &page)
+        return w.Code, page
+    }
+
+    category := createCategory(t, audited, "Audited", nil)
+    product := createProduct(t, audited, "Audited", category.ID)
+
+    assert.Equal(t, http.StatusOK, do(audited, "PUT", fmt.Sprintf("/api/v1/products/%d", product.ID), productRequest("Audited", 12, category.ID)).Code)
+    assert.Equal(t, http.StatusOK, do(audited, "PUT", fmt.Sprintf("/api/v1/products/%d", product.ID), productRequest("Renamed", 12, category.ID)).Code)
+    assert.Equal(t, http.StatusOK, do(audited, "DELETE", fmt.Sprintf("/api/v1/products/%d", product.ID), nil).Code)
+    assert.Equal(t, http.StatusOK, do(audited, "POST", fmt.Sprintf("/api/v1/trash/products/%d/restore", product.ID), nil).Code)
+
+    t.Run("Product History", func(t *testing.T) {
+        code, page := getHistory(fmt.Sprintf("/api/v1/products/%d/history", product.ID))
+        assert.Equal(t, http.StatusOK, code)
+        if assert.Len(t, page.Data, 5) {
+            var actions []string
+            for _, entry := range page.Data {
+                actions = append(actions, entry.Action)
+                assert.Equal(t, "alice", entry.Actor)
+                assert.Equal(t, "audit-e2e", entry.RequestID)
+            }
+            assert.Equal(t, []string{"restore", "delete", "update", "update", "create"}, actions)
+            assert.JSONEq(t, `{"before": 10, "after": 12}`, string(page.Data[3].Changes["price"]))
+        }
+    })
+
+    t.Run("Field Filter", func(t *testing.T) {
+        _, page := getHistory(fmt.Sprintf("/api/v1/products/%d/history?field=name", product.ID))
+        assert.Equal(t, int64(4), page.Meta.Total)
+        code, _ := getHistory(fmt.Sprintf("/api/v1/products/%d/history?field=version", product.ID))
+        assert.Equal(t, http.StatusBadRequest, code)
+    })
+
+    t.Run("Category History", func(t *testing.T) {
+        code, page := getHistory(fmt.Sprintf("/api/v1/categories/%d/history", category.ID))
+        assert.Equal(t, http.StatusOK, code)
+        if assert.Len(t, page.Data, 1) {
+            assert.Equal(t, "create", page.Data[0].Action)
+        }
+    })
+
+    t.Run("Rolled Back Writes Are Not Audited", func(t *testing.T) {
+        w := do(audited, "DELETE", fmt.Sprintf("/api/v1/categories/%d", category.ID), nil)
+        assert.Equal(t, http.StatusConflict, w.Code)
+        _, page := getHistory(fmt.Sprintf("/api/v1/categories/%d/history", category.ID))
+        assert.Equal(t, int64(1), page.Meta.Total)
+    })
+}